@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream"
 )
 
 func adhocBinding() {
@@ -296,51 +298,22 @@ func justPrintError() {
 }
 
 func returnResult() {
-	type Result struct {
-		Error    error
-		Response *http.Response
-	}
 	checkStatus := func(
-		done <-chan interface{},
+		ctx context.Context,
 		urls ...string,
-	) <-chan Result {
-		results := make(chan Result)
-		go func() {
-			defer close(results)
-
-			for _, url := range urls {
-				var result Result
-				resp, err := http.Get(url)
-				result = Result{
-					Error:    err,
-					Response: resp,
-				}
-				select {
-				case <-done:
-					return
-				case results <- result:
-				}
-			}
-		}()
-		return results
+	) stream.Stream[stream.Result[*http.Response]] {
+		return stream.Try(ctx, stream.Generate(ctx, urls...), http.Get)
 	}
 
-	done := make(chan interface{})
-	defer close(done)
+	ctx, cancel := stream.NewContext(context.Background())
+	defer cancel(nil)
 
-	errCount := 0
 	urls := []string{"a", "https://www.google.com", "b", "c", "d"}
-	for result := range checkStatus(done, urls...) {
-		if result.Error != nil {
-			fmt.Printf("error: %v", result.Error)
-			errCount++
-			if errCount >= 3 {
-				fmt.Println("Too many errors, breaking!")
-				break
-			}
-			continue
-		}
-		fmt.Printf("Response: %v\n", result.Response.Status)
+	for response := range stream.StopAfterErrors(ctx, checkStatus(ctx, urls...), 3) {
+		fmt.Printf("Response: %v\n", response.Status)
+	}
+	if err := context.Cause(ctx); err != nil {
+		fmt.Println(err)
 	}
 	// without errCount
 	//Response: 200 OK
@@ -349,6 +322,10 @@ func returnResult() {
 	// after adding errCount
 	//error: Get a: unsupported protocol scheme ""Response: 200 OK
 	//error: Get b: unsupported protocol scheme ""error: Get c: unsupported protocol scheme ""Too many errors, breaking!
+
+	// with stream.StopAfterErrors
+	//Response: 200 OK
+	//stream: too many errors (3), last: Get "c": unsupported protocol scheme ""
 }
 
 func pipeline() {
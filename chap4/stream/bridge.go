@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"context"
+	"sync"
+)
+
+// Bridge flattens a stream of streams into a single stream, draining the
+// inner streams one after another.
+func Bridge[T any](ctx context.Context, chanStream Stream[Stream[T]]) Stream[T] {
+	valStream := make(chan T)
+	Go(ctx, "Bridge", func(g *Goroutine) {
+		defer close(valStream)
+		for {
+			var stream Stream[T]
+			select {
+			case maybeStream, ok := <-chanStream:
+				if !ok {
+					return
+				}
+				stream = maybeStream
+			case <-ctx.Done():
+				return
+			}
+			for val := range OrDone(ctx, stream) {
+				select {
+				case valStream <- val:
+				case <-ctx.Done():
+				}
+			}
+		}
+	})
+	return valStream
+}
+
+// BridgeMode decides how BridgeN orders elements of inner streams that are
+// drained at the same time.
+type BridgeMode int
+
+const (
+	// BridgeContiguous keeps the elements of each inner stream together.
+	// The first inner stream to produce an element is forwarded until it
+	// closes while the others are buffered. An inner stream keeps its slot
+	// until its buffered elements have been forwarded, so no more than k
+	// streams are buffered at once.
+	BridgeContiguous BridgeMode = iota
+	// BridgeInterleave forwards elements as soon as any inner stream
+	// produces them.
+	BridgeInterleave
+)
+
+type bridgeEvent[T any] struct {
+	id     int
+	val    T
+	closed bool
+}
+
+type bridgeQueue[T any] struct {
+	vals   []T
+	closed bool
+}
+
+// BridgeN flattens a stream of streams, draining up to k inner streams at
+// once so that a slow inner stream does not hold up the ones behind it. The
+// output closes once chanStream and every inner stream have closed.
+func BridgeN[T any](ctx context.Context, chanStream Stream[Stream[T]], k int, mode BridgeMode) Stream[T] {
+	if k < 1 {
+		k = 1
+	}
+	valStream := make(chan T)
+	events := make(chan bridgeEvent[T])
+	slots := make(chan struct{}, k)
+
+	Go(ctx, "BridgeN", func(g *Goroutine) {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(events)
+		}()
+		for id := 0; ; id++ {
+			stream, ok := recv(ctx, chanStream)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+			wg.Add(1)
+			Go(ctx, "BridgeN", func(g *Goroutine) {
+				defer wg.Done()
+				if mode == BridgeInterleave {
+					defer func() { <-slots }()
+				}
+				for val := range OrDone(ctx, stream) {
+					select {
+					case <-ctx.Done():
+						return
+					case events <- bridgeEvent[T]{id: id, val: val}:
+					}
+				}
+				select {
+				case <-ctx.Done():
+				case events <- bridgeEvent[T]{id: id, closed: true}:
+				}
+			})
+		}
+	})
+
+	if mode == BridgeInterleave {
+		Go(ctx, "BridgeN", func(g *Goroutine) {
+			defer close(valStream)
+			for e := range events {
+				if e.closed {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case valStream <- e.val:
+				}
+			}
+		})
+		return valStream
+	}
+
+	Go(ctx, "BridgeN", func(g *Goroutine) {
+		defer close(valStream)
+		queues := make(map[int]*bridgeQueue[T])
+		var order []int
+		active := -1
+
+		for events != nil || len(order) > 0 {
+			if active < 0 {
+				for _, id := range order {
+					if q := queues[id]; len(q.vals) > 0 || q.closed {
+						active = id
+						break
+					}
+				}
+			}
+
+			var out chan<- T
+			var next T
+			if active >= 0 {
+				q := queues[active]
+				if len(q.vals) > 0 {
+					out, next = valStream, q.vals[0]
+				} else if q.closed {
+					delete(queues, active)
+					<-slots
+					for i, id := range order {
+						if id == active {
+							order = append(order[:i], order[i+1:]...)
+							break
+						}
+					}
+					active = -1
+					continue
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				q, ok := queues[e.id]
+				if !ok {
+					q = &bridgeQueue[T]{}
+					queues[e.id] = q
+					order = append(order, e.id)
+				}
+				if e.closed {
+					q.closed = true
+				} else {
+					q.vals = append(q.vals, e.val)
+				}
+			case out <- next:
+				q := queues[active]
+				var zero T
+				q.vals[0] = zero
+				q.vals = q.vals[1:]
+			}
+		}
+	})
+	return valStream
+}
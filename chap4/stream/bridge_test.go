@@ -0,0 +1,154 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func genVals(n int) Stream[Stream[int]] {
+	chanStream := make(chan Stream[int])
+	go func() {
+		defer close(chanStream)
+		for i := 0; i < n; i++ {
+			stream := make(chan int, 1)
+			stream <- i
+			close(stream)
+			chanStream <- stream
+		}
+	}()
+	return chanStream
+}
+
+func TestBridge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if actual := collect(Bridge(ctx, genVals(10))); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+// delayedStream emits vals, waiting delay before each one.
+func delayedStream(delay time.Duration, vals ...int) Stream[int] {
+	stream := make(chan int)
+	go func() {
+		defer close(stream)
+		for _, v := range vals {
+			time.Sleep(delay)
+			stream <- v
+		}
+	}()
+	return stream
+}
+
+func TestBridgeN(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     BridgeMode
+		expected []int
+	}{
+		{name: "contiguous", mode: BridgeContiguous, expected: []int{1, 2, 3, 4, 5, 6, 100, 101, 102}},
+		{name: "interleave", mode: BridgeInterleave, expected: []int{1, 2, 3, 4, 5, 6, 100, 101, 102}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// chanStream closes as soon as the last inner stream is handed
+			// over, while the slow stream is still open.
+			chanStream := Generate(ctx,
+				delayedStream(20*time.Millisecond, 100, 101, 102),
+				delayedStream(0, 1, 2, 3),
+				delayedStream(10*time.Millisecond, 4, 5, 6),
+			)
+			actual := collect(BridgeN(ctx, chanStream, 3, tt.mode))
+			if tt.mode == BridgeInterleave {
+				sort.Ints(actual)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBridgeNContiguousBacklog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hold := make(chan struct{})
+	slow := make(chan int)
+	go func() {
+		defer close(slow)
+		slow <- -1
+		<-hold
+	}()
+	// The fast streams are handed over once the slow one is active.
+	active := make(chan struct{})
+	var handedOver atomic.Int32
+	chanStream := make(chan Stream[int])
+	go func() {
+		defer close(chanStream)
+		chanStream <- slow
+		handedOver.Add(1)
+		<-active
+		for i := 0; i < 100; i++ {
+			chanStream <- Generate(ctx, i)
+			handedOver.Add(1)
+		}
+	}()
+
+	valStream := BridgeN(ctx, chanStream, 2, BridgeContiguous)
+	if v := <-valStream; v != -1 {
+		t.Fatalf("expected -1, but received %v", v)
+	}
+	close(active)
+	// The slow stream and one buffered stream hold both slots, so at most
+	// one more stream can be waiting for a slot.
+	time.Sleep(50 * time.Millisecond)
+	if n := handedOver.Load(); n > 3 {
+		t.Errorf("expected at most 3 streams to be taken while the slow one is active, but %v were", n)
+	}
+
+	close(hold)
+	var expected []int
+	for i := 0; i < 100; i++ {
+		expected = append(expected, i)
+	}
+	actual := collect(valStream)
+	sort.Ints(actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestBridgeNSingleMatchesBridge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if actual := collect(BridgeN(ctx, genVals(10), 1, BridgeContiguous)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestBridgeNCancel(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	never := make(chan int)
+	defer close(never)
+	valStream := BridgeN(ctx, Generate[Stream[int]](ctx, never, never), 2, BridgeContiguous)
+	cancel()
+	for range valStream {
+	}
+	waitForGoroutines(t, baseline)
+}
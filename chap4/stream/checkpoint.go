@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Checkpoint persists the offset of the first element of a source that has
+// not been acknowledged yet, so a restarted source can resume from it.
+// Elements acknowledged out of order are remembered until the ones before
+// them are acknowledged too, which makes delivery at-least-once.
+type Checkpoint struct {
+	path string
+
+	mu     sync.Mutex
+	offset int64
+	acked  map[int64]bool
+}
+
+// OpenCheckpoint loads the checkpoint stored at path. A missing file means
+// nothing has been acknowledged yet.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{path: path, acked: make(map[int64]bool)}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return c, nil
+	case err != nil:
+		return nil, err
+	}
+	if c.offset, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err != nil {
+		return nil, fmt.Errorf("checkpoint %v: %w", path, err)
+	}
+	return c, nil
+}
+
+// Offset returns the offset a restarted source should resume from.
+func (c *Checkpoint) Offset() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset
+}
+
+// Ack acknowledges the element at offset and persists the new resume
+// offset if it moved.
+func (c *Checkpoint) Ack(offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset < c.offset {
+		return nil
+	}
+	c.acked[offset] = true
+
+	next := c.offset
+	for c.acked[next] {
+		next++
+	}
+	if next == c.offset {
+		return nil
+	}
+	// The acknowledgements stay until the new offset is on disk, so a
+	// later Ack retries a failed write.
+	if err := c.write(next); err != nil {
+		return err
+	}
+	for o := c.offset; o < next; o++ {
+		delete(c.acked, o)
+	}
+	c.offset = next
+	return nil
+}
+
+// write replaces the checkpoint file with offset. The new contents are
+// synced before the rename and the directory after it, so a crash leaves
+// either the old or the new offset on disk.
+func (c *Checkpoint) write(offset int64) error {
+	dir := filepath.Dir(c.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := fmt.Fprintf(tmp, "%d\n", offset); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Acked is an element of a resumable source. The sink calls Ack once it is
+// done with the element.
+type Acked[T any] struct {
+	Offset int64
+	Value  T
+	cp     *Checkpoint
+}
+
+func (a Acked[T]) Ack() error {
+	return a.cp.Ack(a.Offset)
+}
+
+// ResumeFrom starts source at the checkpoint's offset and numbers its
+// elements from there.
+func ResumeFrom[T any](
+	ctx context.Context,
+	cp *Checkpoint,
+	source func(ctx context.Context, offset int64) Stream[T],
+) Stream[Acked[T]] {
+	ackedStream := make(chan Acked[T])
+	Go(ctx, "ResumeFrom", func(g *Goroutine) {
+		defer close(ackedStream)
+		offset := cp.Offset()
+		for v := range OrDone(ctx, source(ctx, offset)) {
+			select {
+			case <-ctx.Done():
+				return
+			case ackedStream <- Acked[T]{Offset: offset, Value: v, cp: cp}:
+			}
+			offset++
+		}
+	})
+	return ackedStream
+}
+
+// Resume emits values from the checkpoint's offset on.
+func Resume[T any](ctx context.Context, cp *Checkpoint, values ...T) Stream[Acked[T]] {
+	return ResumeFrom(ctx, cp, func(ctx context.Context, offset int64) Stream[T] {
+		return Generate(ctx, values[min(offset, int64(len(values))):]...)
+	})
+}
@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResumeAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intList.checkpoint")
+	intList := []int{1, 2, 3, 4, 5}
+
+	// The first run acknowledges 1 and 2, then receives 3 but crashes
+	// before acknowledging it.
+	ctx, cancel := context.WithCancel(context.Background())
+	cp, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot open checkpoint: %v", err)
+	}
+	var received []int
+	for a := range Resume(ctx, cp, intList...) {
+		received = append(received, a.Value)
+		if a.Value == 3 {
+			break
+		}
+		if err := a.Ack(); err != nil {
+			t.Fatalf("cannot ack %v: %v", a.Offset, err)
+		}
+	}
+	cancel()
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("first run: expected %v, but received %v", expected, received)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	cp, err = OpenCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot reopen checkpoint: %v", err)
+	}
+	received = nil
+	for a := range Resume(ctx, cp, intList...) {
+		received = append(received, a.Value)
+		a.Ack()
+	}
+	if expected := []int{3, 4, 5}; !reflect.DeepEqual(received, expected) {
+		t.Errorf("second run: expected %v, but received %v", expected, received)
+	}
+	if offset := cp.Offset(); offset != 5 {
+		t.Errorf("expected offset 5, but received %v", offset)
+	}
+}
+
+func TestCheckpointOutOfOrderAcks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	cp, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot open checkpoint: %v", err)
+	}
+
+	steps := []struct {
+		ack      int64
+		expected int64
+	}{
+		{ack: 1, expected: 0},
+		{ack: 3, expected: 0},
+		{ack: 0, expected: 2},
+		{ack: 0, expected: 2},
+		{ack: 2, expected: 4},
+	}
+	for _, s := range steps {
+		if err := cp.Ack(s.ack); err != nil {
+			t.Fatalf("cannot ack %v: %v", s.ack, err)
+		}
+		if offset := cp.Offset(); offset != s.expected {
+			t.Errorf("after ack %v: expected offset %v, but received %v", s.ack, s.expected, offset)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != "4\n" {
+		t.Errorf("expected %q on disk, but received %q (%v)", "4\n", b, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be cleaned up, but found %v", entries)
+	}
+}
+
+func TestOpenCheckpointCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	os.WriteFile(path, []byte("not a number"), 0644)
+	if _, err := OpenCheckpoint(path); err == nil {
+		t.Error("expected an error for a corrupt checkpoint")
+	}
+}
+
+func TestCheckpointAckAfterFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	cp, err := OpenCheckpoint(path)
+	if err != nil {
+		t.Fatalf("cannot open checkpoint: %v", err)
+	}
+
+	// A directory in the checkpoint's place makes the rename fail.
+	if err := os.MkdirAll(filepath.Join(path, "in the way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Ack(0); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if offset := cp.Offset(); offset != 0 {
+		t.Errorf("expected offset 0 after a failed write, but received %v", offset)
+	}
+
+	os.RemoveAll(path)
+	for _, offset := range []int64{1, 2} {
+		if err := cp.Ack(offset); err != nil {
+			t.Fatalf("cannot ack %v: %v", offset, err)
+		}
+	}
+	if offset := cp.Offset(); offset != 3 {
+		t.Errorf("expected the earlier ack to count, but received offset %v", offset)
+	}
+}
@@ -0,0 +1,97 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	after := f.After(3 * time.Second)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+	if n := f.Waiters(); n != 2 {
+		t.Fatalf("expected 2 waiters, but received %v", n)
+	}
+
+	f.Advance(time.Second)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Second)) {
+		t.Errorf("expected a tick at 1s, but received %v", tick)
+	}
+	select {
+	case <-after:
+		t.Error("expected After(3s) not to fire after 1s")
+	default:
+	}
+
+	// Ticks nobody received are dropped, like time.Ticker does.
+	f.Advance(2 * time.Second)
+	if at := <-after; !at.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expected After to fire at 3s, but received %v", at)
+	}
+	if tick := <-ticker.C(); !tick.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("expected the buffered tick at 2s, but received %v", tick)
+	}
+	if now := f.Now(); !now.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expected now to be 3s, but received %v", now)
+	}
+	if n := f.Waiters(); n != 1 {
+		t.Errorf("expected only the ticker to be waiting, but %v are", n)
+	}
+}
+
+func TestFakeSleep(t *testing.T) {
+	f := NewFake(time.Time{})
+	woke := make(chan struct{})
+	go func() {
+		defer close(woke)
+		f.Sleep(time.Hour)
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Hour)
+	<-woke
+}
+
+func TestFakeNext(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if _, ok := f.Next(); ok {
+		t.Error("expected no deadline on an idle clock")
+	}
+
+	f.After(3 * time.Second)
+	ticker := f.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	if next, _ := f.Next(); !next.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("expected the ticker at 2s, but received %v", next)
+	}
+	f.Advance(2 * time.Second)
+	if next, _ := f.Next(); !next.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expected After at 3s, but received %v", next)
+	}
+}
+
+func TestFakeTimerStop(t *testing.T) {
+	f := NewFake(time.Time{})
+	timer := f.NewTimer(time.Second)
+	f.BlockUntilArmed(f.Now().Add(time.Second))
+	if !timer.Stop() {
+		t.Error("expected Stop to disarm the timer")
+	}
+	if n := f.Waiters(); n != 0 {
+		t.Errorf("expected a stopped timer not to be waiting, but %v are", n)
+	}
+	if timer.Stop() {
+		t.Error("expected a second Stop to report the timer was already stopped")
+	}
+
+	f.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Error("expected a stopped timer not to fire")
+	default:
+	}
+}
@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"container/heap"
+	"context"
+	"reflect"
+)
+
+// Pair holds one element of each of two streams.
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs the elements of a and b in order and ends with the shorter
+// stream.
+func Zip[A, B any](ctx context.Context, a Stream[A], b Stream[B]) Stream[Pair[A, B]] {
+	zipStream := make(chan Pair[A, B])
+	Go(ctx, "Zip", func(g *Goroutine) {
+		defer close(zipStream)
+		for {
+			first, ok := recv(ctx, a)
+			if !ok {
+				return
+			}
+			second, ok := recv(ctx, b)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case zipStream <- Pair[A, B]{First: first, Second: second}:
+			}
+		}
+	})
+	return zipStream
+}
+
+// CombineLatest emits the latest element of each stream whenever either of
+// them emits, once both have emitted at least once. It ends when both
+// streams are closed.
+func CombineLatest[A, B any](ctx context.Context, a Stream[A], b Stream[B]) Stream[Pair[A, B]] {
+	combinedStream := make(chan Pair[A, B])
+	Go(ctx, "CombineLatest", func(g *Goroutine) {
+		defer close(combinedStream)
+		var latest Pair[A, B]
+		var seenA, seenB bool
+		for a != nil || b != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-a:
+				if !ok {
+					a = nil
+					continue
+				}
+				latest.First, seenA = v, true
+			case v, ok := <-b:
+				if !ok {
+					b = nil
+					continue
+				}
+				latest.Second, seenB = v, true
+			}
+			if !seenA || !seenB {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case combinedStream <- latest:
+			}
+		}
+	})
+	return combinedStream
+}
+
+// Merge forwards the elements of every stream as they arrive. When several
+// streams are ready at once one of them is picked at random, so a busy
+// stream cannot starve the others.
+func Merge[T any](ctx context.Context, streams ...Stream[T]) Stream[T] {
+	mergedStream := make(chan T)
+	Go(ctx, "Merge", func(g *Goroutine) {
+		defer close(mergedStream)
+		cases := make([]reflect.SelectCase, 0, len(streams)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+		for _, s := range streams {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s)})
+		}
+
+		for len(cases) > 1 {
+			chosen, v, ok := reflect.Select(cases)
+			switch {
+			case chosen == 0:
+				return
+			case !ok:
+				cases = append(cases[:chosen], cases[chosen+1:]...)
+				continue
+			}
+			// Set rather than assert so that nil interface elements survive.
+			var elem T
+			reflect.ValueOf(&elem).Elem().Set(v)
+			select {
+			case <-ctx.Done():
+				return
+			case mergedStream <- elem:
+			}
+		}
+	})
+	return mergedStream
+}
+
+type sortedHead[T any] struct {
+	value  T
+	stream int
+}
+
+type sortedHeap[T any] struct {
+	heads []sortedHead[T]
+	cmp   func(a, b T) int
+}
+
+func (h *sortedHeap[T]) Len() int           { return len(h.heads) }
+func (h *sortedHeap[T]) Less(i, j int) bool { return h.cmp(h.heads[i].value, h.heads[j].value) < 0 }
+func (h *sortedHeap[T]) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *sortedHeap[T]) Push(x any)         { h.heads = append(h.heads, x.(sortedHead[T])) }
+func (h *sortedHeap[T]) Pop() any {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// MergeSorted merges streams that are each already sorted by cmp into a
+// single sorted stream. It waits for every open stream to offer an element
+// before emitting the smallest one.
+func MergeSorted[T any](ctx context.Context, cmp func(a, b T) int, streams ...Stream[T]) Stream[T] {
+	sortedStream := make(chan T)
+	Go(ctx, "MergeSorted", func(g *Goroutine) {
+		defer close(sortedStream)
+		h := &sortedHeap[T]{cmp: cmp}
+		for i, s := range streams {
+			v, ok := recv(ctx, s)
+			if ok {
+				h.heads = append(h.heads, sortedHead[T]{value: v, stream: i})
+			} else if ctx.Err() != nil {
+				return
+			}
+		}
+		heap.Init(h)
+
+		for h.Len() > 0 {
+			head := heap.Pop(h).(sortedHead[T])
+			select {
+			case <-ctx.Done():
+				return
+			case sortedStream <- head.value:
+			}
+			if v, ok := recv(ctx, streams[head.stream]); ok {
+				heap.Push(h, sortedHead[T]{value: v, stream: head.stream})
+			} else if ctx.Err() != nil {
+				return
+			}
+		}
+	})
+	return sortedStream
+}
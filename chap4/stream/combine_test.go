@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"cmp"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestZip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expected := []Pair[int, string]{{1, "a"}, {2, "b"}}
+	actual := collect(Zip(ctx, Generate(ctx, 1, 2, 3), Generate(ctx, "a", "b")))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestCombineLatest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := make(chan int)
+	b := make(chan string)
+	combined := CombineLatest(ctx, a, b)
+	go func() {
+		a <- 1
+		a <- 2
+		b <- "x"
+		a <- 3
+		close(a)
+		b <- "y"
+		close(b)
+	}()
+
+	expected := []Pair[int, string]{{2, "x"}, {3, "x"}, {3, "y"}}
+	if actual := collect(combined); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	actual := collect(Merge(ctx, Generate(ctx, 1, 2), Generate(ctx, 3), Generate[int](ctx)))
+	sort.Ints(actual)
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+
+	nils := collect(Merge(ctx, Generate[interface{}](ctx, nil, nil)))
+	if expected := []interface{}{nil, nil}; !reflect.DeepEqual(nils, expected) {
+		t.Errorf("expected %v, but received %v", expected, nils)
+	}
+}
+
+func TestMergeFair(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	merged := Merge(ctx, Repeat(ctx, "busy"), Repeat(ctx, "other"))
+	counts := map[string]int{}
+	for v := range Take(ctx, merged, 1000) {
+		counts[v]++
+	}
+	if counts["other"] < 300 {
+		t.Errorf("expected both streams to be served fairly, but received %v", counts)
+	}
+}
+
+type event struct {
+	at   time.Duration
+	name string
+}
+
+func TestMergeSorted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	byTime := func(a, b event) int { return cmp.Compare(a.at, b.at) }
+	logA := Generate(ctx, event{1, "a1"}, event{4, "a4"}, event{9, "a9"})
+	logB := Generate(ctx, event{2, "b2"}, event{3, "b3"}, event{10, "b10"})
+	logC := Generate(ctx, event{5, "c5"})
+
+	var names []string
+	for e := range MergeSorted(ctx, byTime, logA, logB, logC, Generate[event](ctx)) {
+		names = append(names, e.name)
+	}
+	expected := []string{"a1", "b2", "b3", "a4", "c5", "a9", "b10"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, but received %v", expected, names)
+	}
+}
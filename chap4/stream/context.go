@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDone is the cancellation cause of a context created by DoneContext
+// once its done channel is closed.
+var ErrDone = errors.New("stream: done channel closed")
+
+type cancelKey struct{}
+
+// NewContext returns a pipeline context derived from parent. Any stage
+// running under it can stop the whole pipeline with Fail, and the consumer
+// can read why it stopped with context.Cause:
+//
+//   - context.DeadlineExceeded (or a custom timeout cause) after a deadline,
+//   - the error passed to Fail after an upstream failure,
+//   - context.Canceled, or the cause given to cancel, after a user cancel.
+func NewContext(parent context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	return context.WithValue(ctx, cancelKey{}, cancel), cancel
+}
+
+// Fail cancels the pipeline ctx belongs to with err as its cause. It
+// reports false if ctx was not created by NewContext or DoneContext.
+func Fail(ctx context.Context, err error) bool {
+	cancel, ok := ctx.Value(cancelKey{}).(context.CancelCauseFunc)
+	if !ok {
+		return false
+	}
+	cancel(err)
+	return true
+}
+
+// mustFail panics unless ctx belongs to a pipeline that Fail can stop.
+// Stages that stop the pipeline on an error call it when they are built:
+// under any other context the error would be lost and their output would
+// just end as if it were complete.
+func mustFail(ctx context.Context, stage string) {
+	if !isPipeline(ctx) {
+		panic(fmt.Sprintf("stream: %s needs a context from NewContext or DoneContext to report its errors", stage))
+	}
+}
+
+func isPipeline(ctx context.Context) bool {
+	_, ok := ctx.Value(cancelKey{}).(context.CancelCauseFunc)
+	return ok
+}
+
+// DoneContext adapts a chap4 style done channel to a pipeline context. The
+// context is canceled with ErrDone once done is closed.
+func DoneContext(done <-chan interface{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := NewContext(context.Background())
+	Go(ctx, "DoneContext", func(g *Goroutine) {
+		select {
+		case <-done:
+			cancel(ErrDone)
+		case <-ctx.Done():
+		}
+	})
+	return ctx, func() { cancel(nil) }
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPipelineCause(t *testing.T) {
+	errUpstream := errors.New("upstream failure")
+	errUser := errors.New("user cancel")
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		userStop error
+		fail     error
+		expected error
+	}{
+		{name: "timeout", timeout: 10 * time.Millisecond, expected: context.DeadlineExceeded},
+		{name: "upstream failure", fail: errUpstream, expected: errUpstream},
+		{name: "user cancel", userStop: errUser, expected: errUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				parent, cancel = context.WithTimeout(parent, tt.timeout)
+				defer cancel()
+			}
+			ctx, cancel := NewContext(parent)
+			defer cancel(nil)
+			if tt.userStop != nil {
+				time.AfterFunc(10*time.Millisecond, func() { cancel(tt.userStop) })
+			}
+
+			check := func(v int) (int, error) {
+				if tt.fail != nil && v == 3 {
+					return 0, tt.fail
+				}
+				return v, nil
+			}
+			counter := 0
+			source := RepeatFn(ctx, func() int { counter++; return counter })
+			for range Map(ctx, MapErr(ctx, source, check), func(v int) int { return v * 2 }) {
+			}
+
+			if cause := context.Cause(ctx); !errors.Is(cause, tt.expected) {
+				t.Errorf("expected cause %v, but received %v", tt.expected, cause)
+			}
+		})
+	}
+}
+
+func TestDoneContext(t *testing.T) {
+	done := make(chan interface{})
+	ctx, cancel := DoneContext(done)
+	defer cancel()
+
+	close(done)
+	<-ctx.Done()
+	if cause := context.Cause(ctx); cause != ErrDone {
+		t.Errorf("expected cause %v, but received %v", ErrDone, cause)
+	}
+}
+
+func TestFailWithoutPipeline(t *testing.T) {
+	if Fail(context.Background(), errors.New("boom")) {
+		t.Error("expected Fail to report false for a non-pipeline context")
+	}
+}
+
+func TestFailingStageWithoutPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Under a plain context the budget error would be lost and the stream
+	// would end as if it were complete.
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected StopAfterErrors to panic without a pipeline context")
+		}
+	}()
+	StopAfterErrors(ctx, results(ctx, 1, -1, -2, 4), 2)
+}
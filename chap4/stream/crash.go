@@ -0,0 +1,103 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/internal/goid"
+)
+
+// CrashReport describes a stage goroutine that panicked.
+type CrashReport struct {
+	Time        time.Time
+	Stage       string
+	GoroutineID int64
+	// Element is the last element the goroutine started working on, or nil
+	// if it had not received one.
+	Element interface{}
+	Err     error
+	Stack   string
+}
+
+func (r *CrashReport) Error() string {
+	msg := fmt.Sprintf("stream: stage %q crashed on goroutine %d: %v", r.Stage, r.GoroutineID, r.Err)
+	if r.Element != nil {
+		msg += fmt.Sprintf(" (element %v)", r.Element)
+	}
+	return msg
+}
+
+func (r *CrashReport) Unwrap() error {
+	return r.Err
+}
+
+type (
+	crashKey struct{}
+	stageKey struct{}
+)
+
+// WithCrashHandler returns a copy of ctx under which every stage goroutine
+// that panics passes its report to handle. handle is called on the
+// crashed goroutine, so it must be safe for concurrent use.
+func WithCrashHandler(ctx context.Context, handle func(*CrashReport)) context.Context {
+	return context.WithValue(ctx, crashKey{}, handle)
+}
+
+// withStage returns a copy of ctx under which goroutines started by Go are
+// reported as part of the pipeline stage called name.
+func withStage(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, stageKey{}, name)
+}
+
+// Goroutine is the handle a function started by Go uses to say what it is
+// working on.
+type Goroutine struct {
+	element interface{}
+}
+
+// Working records v as the element the goroutine is processing, so a
+// crash report can name it.
+func (g *Goroutine) Working(v interface{}) {
+	g.element = v
+}
+
+// Go runs fn on a new goroutine for the stage called name. A panic in fn
+// is recovered and turned into a CrashReport, which is passed to the
+// handler set by WithCrashHandler and fails the pipeline with the report
+// as its cause. If ctx has neither a handler nor a pipeline to fail, the
+// stage's output would just end as if it were complete, so Go panics
+// again with the report and kills the process.
+func Go(ctx context.Context, name string, fn func(g *Goroutine)) {
+	if stage, ok := ctx.Value(stageKey{}).(string); ok {
+		name = stage + "/" + name
+	}
+	go func() {
+		g := &Goroutine{}
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			stack := debug.Stack()
+			id, _ := goid.Parse(stack)
+			report := &CrashReport{
+				Time:        time.Now(),
+				Stage:       name,
+				GoroutineID: id,
+				Element:     g.element,
+				Err:         &PanicError{Value: r},
+				Stack:       string(stack),
+			}
+			handle, handled := ctx.Value(crashKey{}).(func(*CrashReport))
+			if handled {
+				handle(report)
+			}
+			if !Fail(ctx, report) && !handled {
+				panic(fmt.Sprintf("%v\n\n%s", report, report.Stack))
+			}
+		}()
+		fn(g)
+	}()
+}
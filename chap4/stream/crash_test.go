@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGoCrashReport(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+	reports := make(chan *CrashReport, 1)
+	ctx = WithCrashHandler(ctx, func(r *CrashReport) { reports <- r })
+
+	out := Map(ctx, Generate(ctx, 1, 2, 0, 4), func(v int) int { return 10 / v })
+	collect(out)
+
+	r := <-reports
+	if r.Stage != "Map" {
+		t.Errorf("expected stage Map, but received %q", r.Stage)
+	}
+	if r.Element != 0 {
+		t.Errorf("expected element 0, but received %v", r.Element)
+	}
+	if r.GoroutineID <= 0 {
+		t.Errorf("expected a goroutine ID, but received %v", r.GoroutineID)
+	}
+	var perr *PanicError
+	if !errors.As(r, &perr) || !strings.Contains(perr.Error(), "divide by zero") {
+		t.Errorf("expected a divide by zero panic, but received %v", r.Err)
+	}
+	if !strings.Contains(r.Stack, "TestGoCrashReport") {
+		t.Errorf("expected the stack to show the panicking function, but received %v", r.Stack)
+	}
+	if cause := context.Cause(ctx); cause != r {
+		t.Errorf("expected the pipeline to fail with the report, but received %v", cause)
+	}
+}
+
+func TestGoCrashInPipeline(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	p := NewPipeline("crash").
+		Source("numbers", func(ctx context.Context) Stream[interface{}] {
+			return Generate[interface{}](ctx, "1", "x")
+		}).
+		Stage("parse", func(v interface{}) (interface{}, error) {
+			return v.(int), nil
+		}, WithInputs("numbers"))
+	out, err := p.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(out)
+
+	var r *CrashReport
+	if !errors.As(context.Cause(ctx), &r) {
+		t.Fatalf("expected a CrashReport, but received %v", context.Cause(ctx))
+	}
+	if r.Stage != "parse/Map" || r.Element != "1" {
+		t.Errorf("expected parse/Map to crash on 1, but received %v on %v", r.Stage, r.Element)
+	}
+}
+
+// TestCrashHelperProcess panics in a stage without a handler or a pipeline
+// context when STREAM_CRASH_HELPER is set.
+func TestCrashHelperProcess(t *testing.T) {
+	if os.Getenv("STREAM_CRASH_HELPER") == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	collect(Map(ctx, Generate(ctx, "a"), func(string) string { panic("oops") }))
+	os.Exit(0)
+}
+
+func TestGoCrashUnhandled(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelperProcess$")
+	cmd.Env = append(os.Environ(), "STREAM_CRASH_HELPER=1")
+	out, err := cmd.CombinedOutput()
+
+	// Without a handler or a pipeline to fail, the crash must not pass for
+	// the end of the stream.
+	if err == nil {
+		t.Fatal("expected the process to crash")
+	}
+	if msg := string(out); !strings.Contains(msg, `stage "Map" crashed`) || !strings.Contains(msg, "oops") {
+		t.Errorf("expected the crash report, but received %q", msg)
+	}
+}
@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an element a stage could not process.
+type DeadLetter struct {
+	Time    time.Time
+	Stage   string
+	Element interface{}
+	Err     error
+	// Stack is the stack of the panicking goroutine. It is empty when the
+	// stage returned an error instead of panicking.
+	Stack string
+}
+
+// PanicError is the Err of a DeadLetter whose stage panicked.
+type PanicError struct {
+	Value interface{}
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
+// DeadLetterSink receives the elements stages could not process.
+// Implementations must be safe for concurrent use.
+type DeadLetterSink interface {
+	Put(DeadLetter) error
+}
+
+// DeadLetterFunc adapts a function to a DeadLetterSink.
+type DeadLetterFunc func(DeadLetter) error
+
+func (f DeadLetterFunc) Put(l DeadLetter) error {
+	return f(l)
+}
+
+// MemoryDeadLetters keeps dead letters in memory.
+type MemoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func (s *MemoryDeadLetters) Put(l DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, l)
+	return nil
+}
+
+// Letters returns every dead letter received so far.
+func (s *MemoryDeadLetters) Letters() []DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadLetter(nil), s.letters...)
+}
+
+// FileDeadLetters appends dead letters to a file, one JSON object per line.
+type FileDeadLetters struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFileDeadLetters opens path for appending, creating it if needed.
+func OpenFileDeadLetters(path string) (*FileDeadLetters, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetters{f: f}, nil
+}
+
+type deadLetterRecord struct {
+	Time    time.Time       `json:"time"`
+	Stage   string          `json:"stage"`
+	Element json.RawMessage `json:"element"`
+	Error   string          `json:"error"`
+	Stack   string          `json:"stack,omitempty"`
+}
+
+func (s *FileDeadLetters) Put(l DeadLetter) error {
+	element, err := json.Marshal(l.Element)
+	if err != nil {
+		element, _ = json.Marshal(fmt.Sprintf("%#v", l.Element))
+	}
+	line, err := json.Marshal(deadLetterRecord{
+		Time:    l.Time,
+		Stage:   l.Stage,
+		Element: element,
+		Error:   l.Err.Error(),
+		Stack:   l.Stack,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileDeadLetters) Close() error {
+	return s.f.Close()
+}
+
+// MapGuarded applies fn to every element of in. An element for which fn
+// returns an error or panics is sent to sink and the stream carries on
+// with the next one. If sink itself fails the pipeline stops with that
+// error, so MapGuarded panics unless ctx comes from NewContext or
+// DoneContext.
+func MapGuarded[T, U any](
+	ctx context.Context,
+	stage string,
+	in Stream[T],
+	fn func(T) (U, error),
+	sink DeadLetterSink,
+) Stream[U] {
+	mustFail(ctx, stage)
+	guardedStream := make(chan U)
+	Go(ctx, stage, func(g *Goroutine) {
+		defer close(guardedStream)
+		for v := range OrDone(ctx, in) {
+			g.Working(v)
+			u, stack, err := applyGuarded(fn, v)
+			if err != nil {
+				l := DeadLetter{Time: time.Now(), Stage: stage, Element: v, Err: err, Stack: stack}
+				if err := sink.Put(l); err != nil {
+					Fail(ctx, fmt.Errorf("dead letter for stage %q: %w", stage, err))
+					return
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case guardedStream <- u:
+			}
+		}
+	})
+	return guardedStream
+}
+
+func applyGuarded[T, U any](fn func(T) (U, error), v T) (u U, stack string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r}
+			stack = string(debug.Stack())
+		}
+	}()
+	u, err = fn(v)
+	return u, "", err
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// toString is chap4's toString stage, which panics on non-string elements.
+func toString(v interface{}) (string, error) {
+	if v == "" {
+		return "", errors.New("empty string")
+	}
+	return v.(string), nil
+}
+
+func TestMapGuarded(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	sink := &MemoryDeadLetters{}
+	in := Generate[interface{}](ctx, "I", 1, "am.", "", "ok")
+	actual := collect(MapGuarded(ctx, "toString", in, toString, sink))
+
+	expected := []string{"I", "am.", "ok"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+
+	letters := sink.Letters()
+	if len(letters) != 2 {
+		t.Fatalf("expected 2 dead letters, but received %v", letters)
+	}
+	var panicErr *PanicError
+	if l := letters[0]; l.Element != 1 || l.Stage != "toString" || !errors.As(l.Err, &panicErr) || !strings.Contains(l.Stack, "applyGuarded") {
+		t.Errorf("expected a panic dead letter for 1 with a stack, but received %+v", l)
+	}
+	if l := letters[1]; l.Element != "" || l.Err.Error() != "empty string" || l.Stack != "" {
+		t.Errorf("expected an error dead letter for \"\", but received %+v", l)
+	}
+}
+
+func TestFileDeadLetters(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink, err := OpenFileDeadLetters(path)
+	if err != nil {
+		t.Fatalf("cannot open sink: %v", err)
+	}
+	collect(MapGuarded(ctx, "toString", Generate[interface{}](ctx, 1, "a", 2.5), toString, sink))
+	if err := sink.Close(); err != nil {
+		t.Fatalf("cannot close sink: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("cannot open %v: %v", path, err)
+	}
+	defer f.Close()
+
+	var elements []interface{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var record deadLetterRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("cannot decode %q: %v", scanner.Text(), err)
+		}
+		if record.Stage != "toString" || !strings.HasPrefix(record.Error, "panic: ") || record.Stack == "" {
+			t.Errorf("unexpected record %+v", record)
+		}
+		var element interface{}
+		json.Unmarshal(record.Element, &element)
+		elements = append(elements, element)
+	}
+	if expected := []interface{}{1.0, 2.5}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("expected elements %v, but received %v", expected, elements)
+	}
+}
+
+func TestDeadLetterFuncFailure(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	errFull := errors.New("sink full")
+	sink := DeadLetterFunc(func(DeadLetter) error { return errFull })
+	collect(MapGuarded(ctx, "toString", Repeat[interface{}](ctx, "a", 1), toString, sink))
+
+	if cause := context.Cause(ctx); !errors.Is(cause, errFull) {
+		t.Errorf("expected cause %v, but received %v", errFull, cause)
+	}
+}
@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+// DistinctStats counts the keys a KeySet has seen before (hits) and the
+// ones it has not (misses).
+type DistinctStats struct {
+	Hits, Misses uint64
+}
+
+// KeySet remembers the keys Distinct has let through. Implementations trade
+// exactness for bounded memory and must be safe for concurrent use.
+type KeySet[K comparable] interface {
+	// Add records k and reports whether it was already present.
+	Add(k K) bool
+	Stats() DistinctStats
+}
+
+// Distinct drops every element whose key set has already seen.
+func Distinct[T any, K comparable](ctx context.Context, in Stream[T], key func(T) K, set KeySet[K]) Stream[T] {
+	distinctStream := make(chan T)
+	Go(ctx, "Distinct", func(g *Goroutine) {
+		defer close(distinctStream)
+		for v := range OrDone(ctx, in) {
+			g.Working(v)
+			if set.Add(key(v)) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case distinctStream <- v:
+			}
+		}
+	})
+	return distinctStream
+}
+
+type distinctCounter struct {
+	hits, misses atomic.Uint64
+}
+
+func (c *distinctCounter) count(hit bool) bool {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return hit
+}
+
+func (c *distinctCounter) Stats() DistinctStats {
+	return DistinctStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// LRUSet remembers exactly the last size distinct keys.
+type LRUSet[K comparable] struct {
+	distinctCounter
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	keys  map[K]*list.Element
+}
+
+func NewLRUSet[K comparable](size int) *LRUSet[K] {
+	return &LRUSet[K]{size: size, order: list.New(), keys: make(map[K]*list.Element, size)}
+}
+
+func (s *LRUSet[K]) Add(k K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.keys[k]; ok {
+		s.order.MoveToFront(e)
+		return s.count(true)
+	}
+	s.keys[k] = s.order.PushFront(k)
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.keys, oldest.Value.(K))
+	}
+	return s.count(false)
+}
+
+// TTLSet remembers every key seen within the last ttl.
+type TTLSet[K comparable] struct {
+	distinctCounter
+	mu      sync.Mutex
+	ttl     time.Duration
+	clk     clock.Clock
+	expires map[K]time.Time
+	queue   []ttlEntry[K]
+}
+
+type ttlEntry[K comparable] struct {
+	key     K
+	expires time.Time
+}
+
+func NewTTLSet[K comparable](ttl time.Duration, clk clock.Clock) *TTLSet[K] {
+	return &TTLSet[K]{ttl: ttl, clk: clk, expires: make(map[K]time.Time)}
+}
+
+func (s *TTLSet[K]) Add(k K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clk.Now()
+
+	// Entries are queued in expiry order; an entry is stale if the key was
+	// seen again after it was queued.
+	for len(s.queue) > 0 && !s.queue[0].expires.After(now) {
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		if s.expires[e.key].Equal(e.expires) {
+			delete(s.expires, e.key)
+		}
+	}
+
+	_, hit := s.expires[k]
+	expires := now.Add(s.ttl)
+	s.expires[k] = expires
+	s.queue = append(s.queue, ttlEntry[K]{key: k, expires: expires})
+	return s.count(hit)
+}
+
+// BloomSet remembers keys in a Bloom filter sized for n keys at a false
+// positive rate of p. It never forgets a key, and once a key is reported as
+// seen when it was not, the element is dropped.
+type BloomSet[K comparable] struct {
+	distinctCounter
+	mu     sync.Mutex
+	bits   []uint64
+	hashes int
+	seed1  maphash.Seed
+	seed2  maphash.Seed
+}
+
+// NewBloomSet returns an empty BloomSet. It panics unless n is positive
+// and p is between 0 and 1.
+func NewBloomSet[K comparable](n int, p float64) *BloomSet[K] {
+	if n < 1 {
+		panic(fmt.Sprintf("stream: BloomSet n must be positive, got %d", n))
+	}
+	if !(p > 0 && p < 1) {
+		panic(fmt.Sprintf("stream: BloomSet p must be between 0 and 1, got %v", p))
+	}
+	m := int(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := max(int(math.Round(float64(m)/float64(n)*math.Ln2)), 1)
+	return &BloomSet[K]{
+		bits:   make([]uint64, (m+63)/64),
+		hashes: k,
+		seed1:  maphash.MakeSeed(),
+		seed2:  maphash.MakeSeed(),
+	}
+}
+
+func (s *BloomSet[K]) Add(k K) bool {
+	h1 := maphash.Comparable(s.seed1, k)
+	h2 := maphash.Comparable(s.seed2, k) | 1
+	m := uint64(len(s.bits) * 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hit := true
+	for i := 0; i < s.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if s.bits[word]&mask == 0 {
+			hit = false
+			s.bits[word] |= mask
+		}
+	}
+	return s.count(hit)
+}
@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+func identity[T any](v T) T { return v }
+
+func TestDistinct(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      KeySet[int]
+		expected []int
+		stats    DistinctStats
+	}{
+		{
+			name:     "lru",
+			set:      NewLRUSet[int](2),
+			expected: []int{1, 2, 3, 1},
+			stats:    DistinctStats{Hits: 3, Misses: 4},
+		},
+		{
+			name:     "bloom",
+			set:      NewBloomSet[int](100, 0.01),
+			expected: []int{1, 2, 3},
+			stats:    DistinctStats{Hits: 4, Misses: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// With room for 2 keys, the LRU set has forgotten 1 by the
+			// time it comes back.
+			in := Generate(ctx, 1, 2, 2, 3, 3, 1, 3)
+			if actual := collect(Distinct(ctx, in, identity[int], tt.set)); !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+			if stats := tt.set.Stats(); stats != tt.stats {
+				t.Errorf("expected %+v, but received %+v", tt.stats, stats)
+			}
+		})
+	}
+}
+
+func TestTTLSet(t *testing.T) {
+	clk := clock.NewFake(time.Time{})
+	set := NewTTLSet[string](10*time.Second, clk)
+
+	steps := []struct {
+		advance time.Duration
+		key     string
+		hit     bool
+	}{
+		{key: "a", hit: false},
+		{advance: 5 * time.Second, key: "a", hit: true}, // refreshes a until 15s
+		{advance: 5 * time.Second, key: "b", hit: false},
+		{advance: 4 * time.Second, key: "a", hit: true},
+		{advance: 11 * time.Second, key: "a", hit: false},
+		{key: "b", hit: false},
+	}
+	for i, s := range steps {
+		clk.Advance(s.advance)
+		if hit := set.Add(s.key); hit != s.hit {
+			t.Errorf("step %v: expected hit %v for %q, but received %v", i, s.hit, s.key, hit)
+		}
+	}
+	if stats, expected := set.Stats(), (DistinctStats{Hits: 2, Misses: 4}); stats != expected {
+		t.Errorf("expected %+v, but received %+v", expected, stats)
+	}
+	if len(set.expires) != 2 {
+		t.Errorf("expected expired keys to be dropped, but %v remain", len(set.expires))
+	}
+}
+
+func TestBloomSetFalsePositiveRate(t *testing.T) {
+	const n = 10000
+	set := NewBloomSet[int](n, 0.01)
+	for i := 0; i < n; i++ {
+		set.Add(i)
+	}
+
+	// Probing adds keys too, so only probe a few to keep the load near n.
+	const probes = 1000
+	falsePositives := 0
+	for i := n; i < n+probes; i++ {
+		if set.Add(i) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / probes; rate > 0.03 {
+		t.Errorf("expected a false positive rate near 1%%, but received %v", rate)
+	}
+}
+
+func TestBloomSetArguments(t *testing.T) {
+	tests := []struct {
+		n int
+		p float64
+	}{
+		{n: 100, p: 0},
+		{n: 100, p: 1},
+		{n: 100, p: -0.5},
+		{n: 0, p: 0.01},
+		{n: -1, p: 0.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n, tt.p), func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected NewBloomSet(%v, %v) to panic", tt.n, tt.p)
+				}
+			}()
+			NewBloomSet[int](tt.n, tt.p)
+		})
+	}
+}
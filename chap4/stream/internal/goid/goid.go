@@ -0,0 +1,23 @@
+// Package goid parses goroutine IDs out of stack traces, for crash reports
+// and leak checks.
+package goid
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Parse parses the ID out of a stack trace header such as the
+// "goroutine N [status]:" line runtime.Stack and debug.Stack write.
+func Parse(stack []byte) (int64, bool) {
+	const prefix = "goroutine "
+	if !bytes.HasPrefix(stack, []byte(prefix)) {
+		return 0, false
+	}
+	stack = stack[len(prefix):]
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, err := strconv.ParseInt(string(stack), 10, 64)
+	return id, err == nil
+}
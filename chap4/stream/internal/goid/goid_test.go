@@ -0,0 +1,18 @@
+package goid
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	if _, ok := Parse(debug.Stack()); !ok {
+		t.Error("expected an ID in the header of debug.Stack")
+	}
+	if id, ok := Parse([]byte("goroutine 42 [running]:\n")); !ok || id != 42 {
+		t.Errorf("expected 42, but received %v", id)
+	}
+	if _, ok := Parse([]byte("panic: oops")); ok {
+		t.Error("expected no ID in a line that is not a stack header")
+	}
+}
@@ -0,0 +1,202 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is the channel operation a stage was blocked on.
+type Op int
+
+const (
+	OpRecv Op = iota
+	OpSend
+)
+
+func (op Op) String() string {
+	if op == OpSend {
+		return "send"
+	}
+	return "recv"
+}
+
+// Metrics records what happens inside pipeline stages. Implementations must
+// be safe for concurrent use.
+type Metrics interface {
+	// ElementIn is called when stage receives an element.
+	ElementIn(stage string)
+	// ElementOut is called when stage sends an element.
+	ElementOut(stage string)
+	// Blocked is called after stage waited d on op.
+	Blocked(stage string, op Op, d time.Duration)
+	// QueueDepth is called with the occupancy of the stage's output
+	// buffer.
+	QueueDepth(stage string, depth, capacity int)
+}
+
+// StageStats is a snapshot of the metrics recorded for one stage.
+type StageStats struct {
+	In, Out       uint64
+	RecvBlocked   time.Duration
+	SendBlocked   time.Duration
+	QueueDepth    int
+	QueueCapacity int
+}
+
+// MemoryMetrics keeps metrics in memory.
+type MemoryMetrics struct {
+	mu     sync.Mutex
+	stages map[string]*StageStats
+}
+
+func NewMemoryMetrics() *MemoryMetrics {
+	return &MemoryMetrics{stages: make(map[string]*StageStats)}
+}
+
+func (m *MemoryMetrics) stage(name string) *StageStats {
+	s, ok := m.stages[name]
+	if !ok {
+		s = &StageStats{}
+		m.stages[name] = s
+	}
+	return s
+}
+
+func (m *MemoryMetrics) ElementIn(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).In++
+}
+
+func (m *MemoryMetrics) ElementOut(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stage(stage).Out++
+}
+
+func (m *MemoryMetrics) Blocked(stage string, op Op, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if op == OpSend {
+		m.stage(stage).SendBlocked += d
+	} else {
+		m.stage(stage).RecvBlocked += d
+	}
+}
+
+func (m *MemoryMetrics) QueueDepth(stage string, depth, capacity int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stage(stage)
+	s.QueueDepth, s.QueueCapacity = depth, capacity
+}
+
+// Stats returns a snapshot of every stage seen so far.
+func (m *MemoryMetrics) Stats() map[string]StageStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]StageStats, len(m.stages))
+	for name, s := range m.stages {
+		stats[name] = *s
+	}
+	return stats
+}
+
+// PrometheusHandler serves the metrics in m in the Prometheus text
+// exposition format.
+func PrometheusHandler(m *MemoryMetrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := m.Stats()
+		names := make([]string, 0, len(stats))
+		for name := range stats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		family := func(name, typ, help string, value func(s StageStats) string) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+			for _, stage := range names {
+				fmt.Fprintf(&b, "%s{stage=%q} %s\n", name, stage, value(stats[stage]))
+			}
+		}
+		family("stream_stage_elements_in_total", "counter", "Elements received by the stage.",
+			func(s StageStats) string { return fmt.Sprint(s.In) })
+		family("stream_stage_elements_out_total", "counter", "Elements sent by the stage.",
+			func(s StageStats) string { return fmt.Sprint(s.Out) })
+		family("stream_stage_recv_blocked_seconds_total", "counter", "Time the stage waited for input.",
+			func(s StageStats) string { return fmt.Sprint(s.RecvBlocked.Seconds()) })
+		family("stream_stage_send_blocked_seconds_total", "counter", "Time the stage waited for its consumer.",
+			func(s StageStats) string { return fmt.Sprint(s.SendBlocked.Seconds()) })
+		family("stream_stage_queue_depth", "gauge", "Elements waiting in the stage's buffer.",
+			func(s StageStats) string { return fmt.Sprint(s.QueueDepth) })
+		family("stream_stage_queue_capacity", "gauge", "Capacity of the stage's buffer.",
+			func(s StageStats) string { return fmt.Sprint(s.QueueCapacity) })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, b.String())
+	})
+}
+
+// Instrument forwards in, the output of stage, unchanged and records it in
+// m. Time blocked on receive is time spent waiting for the stage; time
+// blocked on send is time spent waiting for the downstream one. The
+// occupancy of in is recorded as the stage's queue depth.
+func Instrument[T any](ctx context.Context, stage string, in Stream[T], m Metrics) Stream[T] {
+	return observeSend(ctx, stage, observeRecv(ctx, stage, in, m, true), m, false)
+}
+
+// observeRecv records the elements received from in as stage and, if
+// queue is set, the occupancy of in as stage's queue depth.
+func observeRecv[T any](ctx context.Context, stage string, in Stream[T], m Metrics, queue bool) Stream[T] {
+	observedStream := make(chan T)
+	Go(ctx, stage, func(g *Goroutine) {
+		defer close(observedStream)
+		for {
+			start := time.Now()
+			v, ok := recv(ctx, in)
+			m.Blocked(stage, OpRecv, time.Since(start))
+			if !ok {
+				return
+			}
+			m.ElementIn(stage)
+			if queue {
+				m.QueueDepth(stage, len(in), cap(in))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case observedStream <- v:
+			}
+		}
+	})
+	return observedStream
+}
+
+// observeSend records the elements sent downstream by stage and, if queue
+// is set, the occupancy of in as stage's queue depth.
+func observeSend[T any](ctx context.Context, stage string, in Stream[T], m Metrics, queue bool) Stream[T] {
+	observedStream := make(chan T)
+	Go(ctx, stage, func(g *Goroutine) {
+		defer close(observedStream)
+		for v := range OrDone(ctx, in) {
+			if queue {
+				m.QueueDepth(stage, len(in), cap(in))
+			}
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			case observedStream <- v:
+			}
+			m.Blocked(stage, OpSend, time.Since(start))
+			m.ElementOut(stage)
+		}
+	})
+	return observedStream
+}
@@ -0,0 +1,145 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := func(v interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return v, nil
+	}
+
+	m := NewMemoryMetrics()
+	out, err := NewPipeline("metrics").
+		WithMetrics(m).
+		Source("generator", ints(1, 2, 3, 4)).
+		Stage("slow", slow).
+		Stage("fast", addTo(0)).
+		Run(ctx)
+	if err != nil {
+		t.Fatalf("cannot run pipeline: %v", err)
+	}
+	collect(out)
+
+	stats := m.Stats()
+	for stage, expected := range map[string]StageStats{
+		"generator": {Out: 4},
+		"slow":      {In: 4, Out: 4},
+		"fast":      {In: 4, Out: 4},
+	} {
+		if actual := stats[stage]; actual.In != expected.In || actual.Out != expected.Out {
+			t.Errorf("%v: expected %v in and %v out, but received %v and %v",
+				stage, expected.In, expected.Out, actual.In, actual.Out)
+		}
+	}
+	// The stage behind the bottleneck spends its time waiting for input,
+	// the one in front of it waiting to send.
+	if stats["fast"].RecvBlocked < 10*time.Millisecond {
+		t.Errorf("expected fast to wait on receive, but it waited %v", stats["fast"].RecvBlocked)
+	}
+	if stats["generator"].SendBlocked < 10*time.Millisecond {
+		t.Errorf("expected generator to wait on send, but it waited %v", stats["generator"].SendBlocked)
+	}
+}
+
+type depthMetrics struct {
+	*MemoryMetrics
+	mu     sync.Mutex
+	depths []int
+}
+
+func (m *depthMetrics) QueueDepth(stage string, depth, capacity int) {
+	m.mu.Lock()
+	m.depths = append(m.depths, depth)
+	m.mu.Unlock()
+	m.MemoryMetrics.QueueDepth(stage, depth, capacity)
+}
+
+func TestInstrumentQueueDepth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan int, 4)
+	for i := 0; i < 3; i++ {
+		in <- i
+	}
+	close(in)
+
+	m := &depthMetrics{MemoryMetrics: NewMemoryMetrics()}
+	collect(Instrument(ctx, "buffered", in, m))
+
+	expected := []int{2, 1, 0}
+	if !reflect.DeepEqual(m.depths, expected) {
+		t.Errorf("expected queue depths %v, but received %v", expected, m.depths)
+	}
+	if s := m.Stats()["buffered"]; s.In != 3 || s.Out != 3 || s.QueueCapacity != 4 {
+		t.Errorf("expected 3 in, 3 out and capacity 4, but received %+v", s)
+	}
+}
+
+func TestPipelineQueueDepth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMemoryMetrics()
+	out, err := NewPipeline("depth").
+		WithMetrics(m).
+		Source("generator", ints(1, 2, 3, 4)).
+		Stage("multiply2", multiplyBy(2), WithBuffer(4)).
+		Stage("add1", addTo(1)).
+		Run(ctx)
+	if err != nil {
+		t.Fatalf("cannot run pipeline: %v", err)
+	}
+	collect(out)
+
+	// Every stage reports its own output buffer, sources included.
+	stats := m.Stats()
+	for stage, expected := range map[string]int{"generator": 4, "multiply2": 4, "add1": 0} {
+		if s, ok := stats[stage]; !ok || s.QueueCapacity != expected {
+			t.Errorf("%v: expected capacity %v, but received %+v", stage, expected, s)
+		}
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	m := NewMemoryMetrics()
+	m.ElementIn("b")
+	m.ElementIn("a")
+	m.ElementOut("a")
+	m.Blocked("a", OpSend, 1500*time.Millisecond)
+	m.QueueDepth("a", 2, 8)
+
+	rec := httptest.NewRecorder()
+	PrometheusHandler(m).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, line := range []string{
+		"# TYPE stream_stage_elements_in_total counter",
+		`stream_stage_elements_in_total{stage="a"} 1`,
+		`stream_stage_elements_in_total{stage="b"} 1`,
+		`stream_stage_elements_out_total{stage="b"} 0`,
+		`stream_stage_send_blocked_seconds_total{stage="a"} 1.5`,
+		"# TYPE stream_stage_queue_depth gauge",
+		`stream_stage_queue_depth{stage="a"} 2`,
+		`stream_stage_queue_capacity{stage="a"} 8`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected line %q in\n%s", line, body)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected a text/plain content type, but received %q", ct)
+	}
+}
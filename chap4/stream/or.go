@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+)
+
+// Or returns a channel that closes as soon as any of channels closes or
+// receives a value. Unlike the recursive or from chap4, it runs on a
+// single goroutine however many channels are passed.
+func Or[T any](channels ...<-chan T) <-chan T {
+	switch len(channels) {
+	case 0:
+		return nil
+	case 1:
+		return channels[0]
+	}
+	return AtLeast(1, channels...)
+}
+
+// And returns a channel that closes once every one of channels has closed
+// or received a value.
+func And[T any](channels ...<-chan T) <-chan T {
+	return AtLeast(len(channels), channels...)
+}
+
+// AtLeast returns a channel that closes once k of channels have closed or
+// received a value. If k is larger than len(channels) the returned channel
+// never closes.
+func AtLeast[T any](k int, channels ...<-chan T) <-chan T {
+	if k > len(channels) {
+		return nil
+	}
+	orDone := make(chan T)
+	Go(context.Background(), "AtLeast", func(g *Goroutine) {
+		defer close(orDone)
+		selectN(k, channels)
+	})
+	return orDone
+}
+
+// OrWithIndex is like Or but sends the index of the channel that fired
+// before closing.
+func OrWithIndex[T any](channels ...<-chan T) <-chan int {
+	if len(channels) == 0 {
+		return nil
+	}
+	fired := make(chan int, 1)
+	Go(context.Background(), "OrWithIndex", func(g *Goroutine) {
+		defer close(fired)
+		fired <- selectN(1, channels)
+	})
+	return fired
+}
+
+// selectN blocks until k of channels have fired and returns the index of
+// the last one.
+func selectN[T any](k int, channels []<-chan T) int {
+	cases := make([]reflect.SelectCase, len(channels))
+	indexes := make([]int, len(channels))
+	for i, c := range channels {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)}
+		indexes[i] = i
+	}
+
+	last := -1
+	for fired := 0; fired < k; fired++ {
+		chosen, _, _ := reflect.Select(cases)
+		last = indexes[chosen]
+		cases = append(cases[:chosen], cases[chosen+1:]...)
+		indexes = append(indexes[:chosen], indexes[chosen+1:]...)
+	}
+	return last
+}
@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func sig(after time.Duration) <-chan interface{} {
+	c := make(chan interface{})
+	go func() {
+		defer close(c)
+		time.Sleep(after)
+	}()
+	return c
+}
+
+// orRecursive is the or from chap4's orPattern, kept for comparison.
+func orRecursive(channels ...<-chan interface{}) <-chan interface{} {
+	switch len(channels) {
+	case 0:
+		return nil
+	case 1:
+		return channels[0]
+	}
+
+	orDone := make(chan interface{})
+	go func() {
+		defer close(orDone)
+		switch len(channels) {
+		case 2:
+			select {
+			case <-channels[0]:
+			case <-channels[1]:
+			}
+		default:
+			select {
+			case <-channels[0]:
+			case <-channels[1]:
+			case <-channels[2]:
+			case <-orRecursive(append(channels[3:], orDone)...):
+			}
+		}
+	}()
+	return orDone
+}
+
+func TestSignalCombinators(t *testing.T) {
+	tests := []struct {
+		name     string
+		combine  func(...<-chan interface{}) <-chan interface{}
+		expected time.Duration
+	}{
+		{name: "or", combine: Or[interface{}], expected: 10 * time.Millisecond},
+		{name: "and", combine: And[interface{}], expected: 50 * time.Millisecond},
+		{
+			name: "at least 2",
+			combine: func(channels ...<-chan interface{}) <-chan interface{} {
+				return AtLeast(2, channels...)
+			},
+			expected: 30 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			<-tt.combine(
+				sig(50*time.Millisecond),
+				sig(30*time.Millisecond),
+				sig(10*time.Millisecond),
+				sig(40*time.Millisecond),
+			)
+			if took := time.Since(start); took < tt.expected || took > tt.expected+40*time.Millisecond {
+				t.Errorf("expected to fire after %v, but fired after %v", tt.expected, took)
+			}
+		})
+	}
+}
+
+func TestOrWithIndex(t *testing.T) {
+	never := make(chan interface{})
+	if i := <-OrWithIndex(never, sig(10*time.Millisecond), never); i != 1 {
+		t.Errorf("expected index 1, but received %v", i)
+	}
+}
+
+func TestOrSingleGoroutine(t *testing.T) {
+	channels := make([]<-chan interface{}, 100)
+	for i := range channels {
+		channels[i] = make(chan interface{})
+	}
+	done := make(chan interface{})
+	channels = append(channels, done)
+
+	before := runtime.NumGoroutine()
+	orDone := Or(channels...)
+	if started := runtime.NumGoroutine() - before; started != 1 {
+		t.Errorf("expected 1 goroutine, but %v were started", started)
+	}
+	close(done)
+	<-orDone
+}
+
+func benchmarkOr(b *testing.B, or func(...<-chan interface{}) <-chan interface{}, n int) {
+	for i := 0; i < b.N; i++ {
+		channels := make([]<-chan interface{}, n)
+		for j := range channels {
+			channels[j] = make(chan interface{})
+		}
+		done := make(chan interface{})
+		channels[n-1] = done
+
+		orDone := or(channels...)
+		close(done)
+		<-orDone
+	}
+}
+
+func BenchmarkOr8(b *testing.B)           { benchmarkOr(b, Or[interface{}], 8) }
+func BenchmarkOrRecursive8(b *testing.B)  { benchmarkOr(b, orRecursive, 8) }
+func BenchmarkOr64(b *testing.B)          { benchmarkOr(b, Or[interface{}], 64) }
+func BenchmarkOrRecursive64(b *testing.B) { benchmarkOr(b, orRecursive, 64) }
+
+//❯ go test -run XXX -bench Or -benchmem
+//goos: linux
+//goarch: amd64
+//pkg: github.com/cipepser/go-concurrency/chap4/stream
+//BenchmarkOr8           	  316726	      3682 ns/op	    2336 B/op	      25 allocs/op
+//BenchmarkOrRecursive8  	  131266	      8285 ns/op	    1797 B/op	      19 allocs/op
+//BenchmarkOr64          	   49850	     21120 ns/op	   17824 B/op	     138 allocs/op
+//BenchmarkOrRecursive64 	   31699	     44175 ns/op	   13890 B/op	     130 allocs/op
+//PASS
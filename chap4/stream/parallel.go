@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"context"
+	"sync"
+)
+
+type indexed[T any] struct {
+	index int
+	value T
+}
+
+// ParallelMap applies fn to the elements of in on workers goroutines and
+// emits the results in input order. At most workers elements are in flight
+// at once, so a slow element holds back the ones behind it rather than
+// letting them pile up.
+func ParallelMap[T, U any](ctx context.Context, in Stream[T], workers int, fn func(T) U) Stream[U] {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan indexed[T])
+	results := make(chan indexed[U])
+	slots := make(chan struct{}, workers)
+	orderedStream := make(chan U)
+
+	Go(ctx, "ParallelMap", func(g *Goroutine) {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			v, ok := recv(ctx, in)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- indexed[T]{index: i, value: v}:
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		Go(ctx, "ParallelMap", func(g *Goroutine) {
+			defer wg.Done()
+			for job := range jobs {
+				g.Working(job.value)
+				select {
+				case <-ctx.Done():
+					return
+				case results <- indexed[U]{index: job.index, value: fn(job.value)}:
+				}
+			}
+		})
+	}
+	Go(ctx, "ParallelMap", func(g *Goroutine) {
+		wg.Wait()
+		close(results)
+	})
+
+	Go(ctx, "ParallelMap", func(g *Goroutine) {
+		defer close(orderedStream)
+		pending := make(map[int]U, workers)
+		next := 0
+		for r := range OrDone(ctx, results) {
+			pending[r.index] = r.value
+			for {
+				v, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				select {
+				case <-ctx.Done():
+					return
+				case orderedStream <- v:
+				}
+				<-slots
+				next++
+			}
+		}
+	})
+	return orderedStream
+}
+
+// ParallelMapUnordered applies fn to the elements of in on workers
+// goroutines and emits each result as soon as it is ready.
+func ParallelMapUnordered[T, U any](ctx context.Context, in Stream[T], workers int, fn func(T) U) Stream[U] {
+	if workers < 1 {
+		workers = 1
+	}
+	mappedStream := make(chan U)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		Go(ctx, "ParallelMapUnordered", func(g *Goroutine) {
+			defer wg.Done()
+			for {
+				v, ok := recv(ctx, in)
+				if !ok {
+					return
+				}
+				g.Working(v)
+				select {
+				case <-ctx.Done():
+					return
+				case mappedStream <- fn(v):
+				}
+			}
+		})
+	}
+	Go(ctx, "ParallelMapUnordered", func(g *Goroutine) {
+		wg.Wait()
+		close(mappedStream)
+	})
+	return mappedStream
+}
@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for d := 2; d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// slowPrime reports n when it is prime and -1 otherwise, taking longer for
+// small numbers so that workers finish out of order.
+func slowPrime(n int) int {
+	time.Sleep(time.Duration(20-n%20) * 100 * time.Microsecond)
+	if isPrime(n) {
+		return n
+	}
+	return -1
+}
+
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: expected at most %v, but %v are running", baseline, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		stage    func(context.Context, Stream[int], int, func(int) int) Stream[int]
+		sorted   bool
+		expected []int
+	}{
+		{name: "ordered", stage: ParallelMap[int, int], expected: []int{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}},
+		{name: "unordered", stage: ParallelMapUnordered[int, int], sorted: true, expected: []int{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ints := make([]int, 30)
+			for i := range ints {
+				ints[i] = i
+			}
+			primes := Filter(ctx, tt.stage(ctx, Generate(ctx, ints...), 4, slowPrime), func(v int) bool { return v > 0 })
+			actual := collect(primes)
+			if tt.sorted {
+				sort.Ints(actual)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParallelMapTakeNoLeak(t *testing.T) {
+	for _, stage := range []func(context.Context, Stream[int], int, func(int) int) Stream[int]{
+		ParallelMap[int, int],
+		ParallelMapUnordered[int, int],
+	} {
+		baseline := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		n := 0
+		source := RepeatFn(ctx, func() int { n++; return n })
+		primes := Filter(ctx, stage(ctx, source, 8, slowPrime), func(v int) bool { return v > 0 })
+		if actual := collect(Take(ctx, primes, 5)); len(actual) != 5 {
+			t.Errorf("expected 5 primes, but received %v", actual)
+		}
+
+		cancel()
+		waitForGoroutines(t, baseline)
+	}
+}
@@ -0,0 +1,387 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrSkip can be returned by a StageFunc to drop the element.
+var ErrSkip = errors.New("stream: skip element")
+
+// StageFunc transforms a single element of a Pipeline stage.
+type StageFunc func(v interface{}) (interface{}, error)
+
+// StageError is the cancellation cause of a pipeline whose stage failed.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (err *StageError) Error() string {
+	return fmt.Sprintf("stage %q: %v", err.Stage, err.Err)
+}
+
+func (err *StageError) Unwrap() error {
+	return err.Err
+}
+
+// StageInfo describes a single stage of a Pipeline.
+type StageInfo struct {
+	Name      string   `json:"name"`
+	Source    bool     `json:"source,omitempty"`
+	Inputs    []string `json:"inputs,omitempty"`
+	Buffer    int      `json:"buffer,omitempty"`
+	Workers   int      `json:"workers,omitempty"`
+	Unordered bool     `json:"unordered,omitempty"`
+}
+
+// StageOption configures a stage added to a Pipeline.
+type StageOption func(*StageInfo)
+
+// WithInputs sets the stages a stage reads from. By default a stage reads
+// from the stage added just before it. Elements of several inputs are
+// merged; a stage read by several others sends each of them every element.
+func WithInputs(names ...string) StageOption {
+	return func(s *StageInfo) { s.Inputs = names }
+}
+
+// WithBuffer sets the capacity of the stage's output channel.
+func WithBuffer(n int) StageOption {
+	return func(s *StageInfo) { s.Buffer = n }
+}
+
+// WithWorkers runs the stage on n goroutines. Results keep their input
+// order unless WithUnordered is also given.
+func WithWorkers(n int) StageOption {
+	return func(s *StageInfo) { s.Workers = n }
+}
+
+// WithUnordered lets a stage with several workers emit results as soon as
+// they are ready.
+func WithUnordered() StageOption {
+	return func(s *StageInfo) { s.Unordered = true }
+}
+
+type pipelineStage struct {
+	StageInfo
+	source func(ctx context.Context) Stream[interface{}]
+	fn     StageFunc
+}
+
+// Pipeline describes a graph of named stages that can be validated,
+// exported for review and run.
+type Pipeline struct {
+	name    string
+	stages  []*pipelineStage
+	metrics Metrics
+}
+
+// NewPipeline returns an empty pipeline called name.
+func NewPipeline(name string) *Pipeline {
+	return &Pipeline{name: name}
+}
+
+// WithMetrics makes every stage record its throughput, blocking time and
+// output buffer occupancy in m.
+func (p *Pipeline) WithMetrics(m Metrics) *Pipeline {
+	p.metrics = m
+	return p
+}
+
+// Source adds a stage that produces elements with fn. fn decides the
+// buffer of its stream and how many goroutines fill it, so WithBuffer,
+// WithWorkers and WithUnordered are invalid for a source.
+func (p *Pipeline) Source(
+	name string,
+	fn func(ctx context.Context) Stream[interface{}],
+	opts ...StageOption,
+) *Pipeline {
+	s := &pipelineStage{StageInfo: StageInfo{Name: name, Source: true}, source: fn}
+	for _, opt := range opts {
+		opt(&s.StageInfo)
+	}
+	p.stages = append(p.stages, s)
+	return p
+}
+
+// Stage adds a stage that applies fn to every element of its inputs.
+func (p *Pipeline) Stage(name string, fn StageFunc, opts ...StageOption) *Pipeline {
+	s := &pipelineStage{StageInfo: StageInfo{Name: name}, fn: fn}
+	if len(p.stages) > 0 {
+		s.Inputs = []string{p.stages[len(p.stages)-1].Name}
+	}
+	for _, opt := range opts {
+		opt(&s.StageInfo)
+	}
+	p.stages = append(p.stages, s)
+	return p
+}
+
+// Stages returns a description of every stage in the order they were added.
+func (p *Pipeline) Stages() []StageInfo {
+	infos := make([]StageInfo, len(p.stages))
+	for i, s := range p.stages {
+		infos[i] = s.StageInfo
+		infos[i].Inputs = append([]string(nil), s.Inputs...)
+	}
+	return infos
+}
+
+// Validate reports every problem that would keep the pipeline from running.
+func (p *Pipeline) Validate() error {
+	_, err := p.order()
+	return err
+}
+
+// order validates the graph and returns its stages in topological order.
+func (p *Pipeline) order() ([]*pipelineStage, error) {
+	if len(p.stages) == 0 {
+		return nil, fmt.Errorf("pipeline %q has no stages", p.name)
+	}
+
+	var errs []error
+	byName := make(map[string]*pipelineStage, len(p.stages))
+	for i, s := range p.stages {
+		switch _, dup := byName[s.Name]; {
+		case s.Name == "":
+			errs = append(errs, fmt.Errorf("stage %d has no name", i))
+		case dup:
+			errs = append(errs, fmt.Errorf("stage %q is defined more than once", s.Name))
+		default:
+			byName[s.Name] = s
+		}
+	}
+
+	indegree := make(map[string]int, len(p.stages))
+	consumers := make(map[string][]string, len(p.stages))
+	for _, s := range p.stages {
+		switch {
+		case s.Source && len(s.Inputs) > 0:
+			errs = append(errs, fmt.Errorf("source %q cannot have inputs", s.Name))
+		case !s.Source && len(s.Inputs) == 0:
+			errs = append(errs, fmt.Errorf("stage %q has no inputs", s.Name))
+		}
+		switch {
+		case s.Source && (s.Buffer != 0 || s.Workers != 0 || s.Unordered):
+			errs = append(errs, fmt.Errorf("source %q cannot have a buffer or workers", s.Name))
+		case s.Buffer < 0 || s.Workers < 0:
+			errs = append(errs, fmt.Errorf("stage %q has a negative buffer or worker count", s.Name))
+		}
+		for _, in := range s.Inputs {
+			if _, ok := byName[in]; !ok {
+				errs = append(errs, fmt.Errorf("stage %q reads from unknown stage %q", s.Name, in))
+				continue
+			}
+			indegree[s.Name]++
+			consumers[in] = append(consumers[in], s.Name)
+		}
+	}
+
+	var sinks []string
+	for _, s := range p.stages {
+		if byName[s.Name] == s && len(consumers[s.Name]) == 0 {
+			sinks = append(sinks, s.Name)
+		}
+	}
+	if len(sinks) != 1 {
+		errs = append(errs, fmt.Errorf("pipeline %q must end in exactly one stage, but ends in %q", p.name, sinks))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var ordered []*pipelineStage
+	var ready []string
+	for _, s := range p.stages {
+		if indegree[s.Name] == 0 {
+			ready = append(ready, s.Name)
+		}
+	}
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byName[name])
+		for _, c := range consumers[name] {
+			if indegree[c]--; indegree[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	if len(ordered) != len(p.stages) {
+		return nil, fmt.Errorf("pipeline %q contains a cycle", p.name)
+	}
+	return ordered, nil
+}
+
+// Run validates the pipeline and starts every stage. It returns the output
+// of the final stage. A failing stage stops the whole pipeline with a
+// *StageError; pass a context from NewContext to read it with
+// context.Cause.
+func (p *Pipeline) Run(ctx context.Context) (Stream[interface{}], error) {
+	ordered, err := p.order()
+	if err != nil {
+		return nil, err
+	}
+
+	var release context.CancelCauseFunc
+	if !isPipeline(ctx) {
+		ctx, release = NewContext(ctx)
+	}
+
+	// pending holds, for every stage, the streams its consumers have not
+	// claimed yet.
+	pending := make(map[string][]Stream[interface{}], len(ordered))
+	consumers := make(map[string]int, len(ordered))
+	for _, s := range ordered {
+		for _, in := range s.Inputs {
+			consumers[in]++
+		}
+	}
+
+	var out Stream[interface{}]
+	for _, s := range ordered {
+		if s.Source {
+			out = s.source(withStage(ctx, s.Name))
+			if p.metrics != nil {
+				out = observeSend(ctx, s.Name, out, p.metrics, true)
+			}
+		} else {
+			inputs := make([]Stream[interface{}], len(s.Inputs))
+			for i, in := range s.Inputs {
+				inputs[i] = pending[in][0]
+				pending[in] = pending[in][1:]
+			}
+			in := inputs[0]
+			if len(inputs) > 1 {
+				in = Merge(ctx, inputs...)
+			}
+			out = p.runStage(ctx, s, in)
+		}
+
+		switch n := consumers[s.Name]; {
+		case n == 1:
+			pending[s.Name] = []Stream[interface{}]{out}
+		case n > 1:
+			pending[s.Name] = TeeN(ctx, out, n, TeePolicy{Mode: TeeBlock}).Streams
+		}
+	}
+
+	if release == nil {
+		return out, nil
+	}
+	sinkStream := make(chan interface{})
+	Go(ctx, p.name, func(g *Goroutine) {
+		defer release(nil)
+		defer close(sinkStream)
+		for v := range OrDone(ctx, out) {
+			select {
+			case <-ctx.Done():
+				return
+			case sinkStream <- v:
+			}
+		}
+	})
+	return sinkStream, nil
+}
+
+type stageResult struct {
+	v   interface{}
+	err error
+}
+
+func (p *Pipeline) runStage(ctx context.Context, s *pipelineStage, in Stream[interface{}]) Stream[interface{}] {
+	if p.metrics != nil {
+		in = observeRecv(ctx, s.Name, in, p.metrics, false)
+	}
+	apply := func(v interface{}) stageResult {
+		u, err := s.fn(v)
+		return stageResult{v: u, err: err}
+	}
+
+	var results Stream[stageResult]
+	stageCtx := withStage(ctx, s.Name)
+	switch {
+	case s.Workers > 1 && s.Unordered:
+		results = ParallelMapUnordered(stageCtx, in, s.Workers, apply)
+	case s.Workers > 1:
+		results = ParallelMap(stageCtx, in, s.Workers, apply)
+	default:
+		results = Map(stageCtx, in, apply)
+	}
+
+	stageStream := make(chan interface{}, s.Buffer)
+	Go(ctx, s.Name, func(g *Goroutine) {
+		defer close(stageStream)
+		for r := range results {
+			if errors.Is(r.err, ErrSkip) {
+				continue
+			}
+			if r.err != nil {
+				Fail(ctx, &StageError{Stage: s.Name, Err: r.err})
+				return
+			}
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			case stageStream <- r.v:
+			}
+			if p.metrics != nil {
+				p.metrics.Blocked(s.Name, OpSend, time.Since(start))
+				p.metrics.ElementOut(s.Name)
+				p.metrics.QueueDepth(s.Name, len(stageStream), cap(stageStream))
+			}
+		}
+	})
+	return stageStream
+}
+
+// MarshalJSON describes the pipeline and its stages as JSON.
+func (p *Pipeline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name   string      `json:"name"`
+		Stages []StageInfo `json:"stages"`
+	}{
+		Name:   p.name,
+		Stages: p.Stages(),
+	})
+}
+
+// WriteDOT writes the pipeline as a Graphviz digraph.
+func (p *Pipeline) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", p.name)
+	fmt.Fprintf(&b, "\trankdir=LR;\n")
+	for _, s := range p.stages {
+		label := []string{s.Name}
+		if s.Buffer > 0 {
+			label = append(label, fmt.Sprintf("buffer=%d", s.Buffer))
+		}
+		if s.Workers > 1 {
+			workers := fmt.Sprintf("workers=%d", s.Workers)
+			if s.Unordered {
+				workers += " unordered"
+			}
+			label = append(label, workers)
+		}
+		shape := "box"
+		if s.Source {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(&b, "\t%q [shape=%s, label=%q];\n", s.Name, shape, strings.Join(label, "\n"))
+	}
+	for _, s := range p.stages {
+		for _, in := range s.Inputs {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", in, s.Name)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
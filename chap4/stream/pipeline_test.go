@@ -0,0 +1,226 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func ints(values ...int) func(ctx context.Context) Stream[interface{}] {
+	return func(ctx context.Context) Stream[interface{}] {
+		vals := make([]interface{}, len(values))
+		for i, v := range values {
+			vals[i] = v
+		}
+		return Generate(ctx, vals...)
+	}
+}
+
+func multiplyBy(m int) StageFunc {
+	return func(v interface{}) (interface{}, error) { return v.(int) * m, nil }
+}
+
+func addTo(a int) StageFunc {
+	return func(v interface{}) (interface{}, error) { return v.(int) + a, nil }
+}
+
+func generatePipeline() *Pipeline {
+	return NewPipeline("generatePipeline").
+		Source("generator", ints(1, 2, 3, 4)).
+		Stage("multiply2", multiplyBy(2), WithBuffer(4)).
+		Stage("add1", addTo(1), WithWorkers(2)).
+		Stage("multiply2again", multiplyBy(2))
+}
+
+func TestPipelineRun(t *testing.T) {
+	odd := func(v interface{}) (interface{}, error) {
+		if v.(int)%2 == 0 {
+			return nil, ErrSkip
+		}
+		return v, nil
+	}
+
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		sorted   bool
+		expected []interface{}
+	}{
+		{
+			name:     "linear",
+			pipeline: generatePipeline(),
+			expected: []interface{}{6, 10, 14, 18},
+		},
+		{
+			name: "diamond",
+			pipeline: NewPipeline("diamond").
+				Source("generator", ints(1, 2, 3)).
+				Stage("multiply10", multiplyBy(10), WithInputs("generator")).
+				Stage("multiply100", multiplyBy(100), WithInputs("generator")).
+				Stage("sum", addTo(0), WithInputs("multiply10", "multiply100")),
+			sorted:   true,
+			expected: []interface{}{10, 20, 30, 100, 200, 300},
+		},
+		{
+			name: "skip",
+			pipeline: NewPipeline("skip").
+				Source("generator", ints(1, 2, 3, 4, 5)).
+				Stage("odd", odd, WithWorkers(3), WithUnordered()),
+			sorted:   true,
+			expected: []interface{}{1, 3, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			out, err := tt.pipeline.Run(ctx)
+			if err != nil {
+				t.Fatalf("cannot run pipeline: %v", err)
+			}
+			actual := collect(out)
+			if tt.sorted {
+				sort.Slice(actual, func(i, j int) bool { return actual[i].(int) < actual[j].(int) })
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPipelineStageFailure(t *testing.T) {
+	errBad := errors.New("bad element")
+	failOn3 := func(v interface{}) (interface{}, error) {
+		if v.(int) == 3 {
+			return nil, errBad
+		}
+		return v, nil
+	}
+
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	out, err := NewPipeline("failing").
+		Source("generator", func(ctx context.Context) Stream[interface{}] { return Repeat[interface{}](ctx, 1, 2, 3) }).
+		Stage("check", failOn3).
+		Run(ctx)
+	if err != nil {
+		t.Fatalf("cannot run pipeline: %v", err)
+	}
+	for range out {
+	}
+
+	var stageErr *StageError
+	if cause := context.Cause(ctx); !errors.As(cause, &stageErr) || stageErr.Stage != "check" || !errors.Is(cause, errBad) {
+		t.Errorf("expected a StageError for %q wrapping %v, but received %v", "check", errBad, cause)
+	}
+}
+
+func TestPipelineValidate(t *testing.T) {
+	identity := func(v interface{}) (interface{}, error) { return v, nil }
+
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		expected []string
+	}{
+		{
+			name:     "empty",
+			pipeline: NewPipeline("empty"),
+			expected: []string{`pipeline "empty" has no stages`},
+		},
+		{
+			name: "duplicate and unknown",
+			pipeline: NewPipeline("p").
+				Source("a", ints(1)).
+				Stage("b", identity).
+				Stage("b", identity, WithInputs("missing")),
+			expected: []string{
+				`stage "b" is defined more than once`,
+				`stage "b" reads from unknown stage "missing"`,
+			},
+		},
+		{
+			name: "no inputs and two sinks",
+			pipeline: NewPipeline("p").
+				Stage("orphan", identity).
+				Source("a", ints(1)),
+			expected: []string{
+				`stage "orphan" has no inputs`,
+				`pipeline "p" must end in exactly one stage, but ends in ["orphan" "a"]`,
+			},
+		},
+		{
+			name: "source options",
+			pipeline: NewPipeline("p").
+				Source("a", ints(1), WithBuffer(4), WithWorkers(2)).
+				Stage("b", identity, WithBuffer(-1)),
+			expected: []string{
+				`source "a" cannot have a buffer or workers`,
+				`stage "b" has a negative buffer or worker count`,
+			},
+		},
+		{
+			name: "cycle",
+			pipeline: NewPipeline("p").
+				Source("a", ints(1)).
+				Stage("b", identity, WithInputs("a", "c")).
+				Stage("c", identity, WithInputs("b")).
+				Stage("d", identity, WithInputs("c")),
+			expected: []string{`pipeline "p" contains a cycle`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pipeline.Validate()
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			if actual := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %q, but received %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPipelineExport(t *testing.T) {
+	var dot strings.Builder
+	if err := generatePipeline().WriteDOT(&dot); err != nil {
+		t.Fatalf("cannot write DOT: %v", err)
+	}
+	expectedDOT := `digraph "generatePipeline" {
+	rankdir=LR;
+	"generator" [shape=ellipse, label="generator"];
+	"multiply2" [shape=box, label="multiply2\nbuffer=4"];
+	"add1" [shape=box, label="add1\nworkers=2"];
+	"multiply2again" [shape=box, label="multiply2again"];
+	"generator" -> "multiply2";
+	"multiply2" -> "add1";
+	"add1" -> "multiply2again";
+}
+`
+	if dot.String() != expectedDOT {
+		t.Errorf("expected DOT\n%s\nbut received\n%s", expectedDOT, dot.String())
+	}
+
+	b, err := json.Marshal(generatePipeline())
+	if err != nil {
+		t.Fatalf("cannot marshal JSON: %v", err)
+	}
+	expectedJSON := `{"name":"generatePipeline","stages":[` +
+		`{"name":"generator","source":true},` +
+		`{"name":"multiply2","inputs":["generator"],"buffer":4},` +
+		`{"name":"add1","inputs":["multiply2"],"workers":2},` +
+		`{"name":"multiply2again","inputs":["add1"]}]}`
+	if string(b) != expectedJSON {
+		t.Errorf("expected JSON %s, but received %s", expectedJSON, b)
+	}
+}
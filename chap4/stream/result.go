@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyErrors is wrapped by the cause of a pipeline stopped by
+// StopAfterErrors or StopAtErrorRate.
+var ErrTooManyErrors = errors.New("stream: too many errors")
+
+// Result carries either a value or the error that kept it from being
+// produced, like the Result type of chap4's returnResult.
+type Result[T any] struct {
+	Value T
+	Error error
+}
+
+// BudgetError is the cause of a pipeline that used up its error budget.
+// Errors holds the errors that counted against the budget.
+type BudgetError struct {
+	Errors []error
+}
+
+func (err *BudgetError) Error() string {
+	if len(err.Errors) == 0 {
+		return ErrTooManyErrors.Error()
+	}
+	return fmt.Sprintf("%v (%d), last: %v", ErrTooManyErrors, len(err.Errors), err.Errors[len(err.Errors)-1])
+}
+
+func (err *BudgetError) Unwrap() []error {
+	return append([]error{ErrTooManyErrors}, err.Errors...)
+}
+
+// Try applies fn to every element of in and emits its value or error as a
+// Result.
+func Try[T, U any](ctx context.Context, in Stream[T], fn func(T) (U, error)) Stream[Result[U]] {
+	return Map(ctx, in, func(v T) Result[U] {
+		u, err := fn(v)
+		return Result[U]{Value: u, Error: err}
+	})
+}
+
+// StopAfterErrors emits the values of in and skips its errors until n
+// errors have been seen. It then stops the pipeline with a *BudgetError.
+// StopAfterErrors panics unless ctx comes from NewContext or DoneContext.
+func StopAfterErrors[T any](ctx context.Context, in Stream[Result[T]], n int) Stream[T] {
+	mustFail(ctx, "StopAfterErrors")
+	valueStream := make(chan T)
+	Go(ctx, "StopAfterErrors", func(g *Goroutine) {
+		defer close(valueStream)
+		var errs []error
+		for r := range OrDone(ctx, in) {
+			if r.Error != nil {
+				if errs = append(errs, r.Error); len(errs) >= n {
+					Fail(ctx, &BudgetError{Errors: errs})
+					return
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- r.Value:
+			}
+		}
+	})
+	return valueStream
+}
+
+// StopAtErrorRate emits the values of in and skips its errors until the
+// share of errors among the last window results reaches rate. It then
+// stops the pipeline with a *BudgetError. The rate is not checked before
+// window results have been seen. StopAtErrorRate panics unless window is
+// positive, rate is above zero and ctx comes from NewContext or
+// DoneContext.
+func StopAtErrorRate[T any](ctx context.Context, in Stream[Result[T]], rate float64, window int) Stream[T] {
+	if window < 1 {
+		panic(fmt.Sprintf("stream: StopAtErrorRate window must be positive, got %d", window))
+	}
+	if !(rate > 0) {
+		panic(fmt.Sprintf("stream: StopAtErrorRate rate must be above zero, got %v", rate))
+	}
+	mustFail(ctx, "StopAtErrorRate")
+	valueStream := make(chan T)
+	Go(ctx, "StopAtErrorRate", func(g *Goroutine) {
+		defer close(valueStream)
+		recent := make([]error, 0, window)
+		failed := 0
+		for r := range OrDone(ctx, in) {
+			if len(recent) == window {
+				if recent[0] != nil {
+					failed--
+				}
+				recent = recent[1:]
+			}
+			recent = append(recent, r.Error)
+			if r.Error != nil {
+				failed++
+			}
+
+			if len(recent) == window && float64(failed)/float64(window) >= rate {
+				var errs []error
+				for _, err := range recent {
+					if err != nil {
+						errs = append(errs, err)
+					}
+				}
+				Fail(ctx, &BudgetError{Errors: errs})
+				return
+			}
+			if r.Error != nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- r.Value:
+			}
+		}
+	})
+	return valueStream
+}
+
+// RouteErrors splits in into its values and its errors. Both outputs must
+// be read for the stage to make progress.
+func RouteErrors[T any](ctx context.Context, in Stream[Result[T]]) (Stream[T], Stream[error]) {
+	valueStream := make(chan T)
+	errStream := make(chan error)
+	Go(ctx, "RouteErrors", func(g *Goroutine) {
+		defer close(valueStream)
+		defer close(errStream)
+		for r := range OrDone(ctx, in) {
+			if r.Error != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case errStream <- r.Error:
+				}
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- r.Value:
+			}
+		}
+	})
+	return valueStream, errStream
+}
@@ -0,0 +1,148 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// results turns every negative value into an error.
+func results(ctx context.Context, values ...int) Stream[Result[int]] {
+	return Try(ctx, Generate(ctx, values...), func(v int) (int, error) {
+		if v < 0 {
+			return 0, fmt.Errorf("negative value: %v", v)
+		}
+		return v, nil
+	})
+}
+
+func TestErrorBudgets(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   func(ctx context.Context, in Stream[Result[int]]) Stream[int]
+		values   []int
+		expected []int
+		errs     int
+	}{
+		{
+			name: "stop after 3 errors",
+			policy: func(ctx context.Context, in Stream[Result[int]]) Stream[int] {
+				return StopAfterErrors(ctx, in, 3)
+			},
+			values:   []int{-1, 1, -2, -3, 2},
+			expected: []int{1},
+			errs:     3,
+		},
+		{
+			name: "within error count",
+			policy: func(ctx context.Context, in Stream[Result[int]]) Stream[int] {
+				return StopAfterErrors(ctx, in, 3)
+			},
+			values:   []int{-1, 1, -2, 2},
+			expected: []int{1, 2},
+		},
+		{
+			name: "stop at 50% of last 4",
+			policy: func(ctx context.Context, in Stream[Result[int]]) Stream[int] {
+				return StopAtErrorRate(ctx, in, 0.5, 4)
+			},
+			values:   []int{1, -1, 2, 3, 4, -2, 5, -3, 6},
+			expected: []int{1, 2, 3, 4, 5},
+			errs:     2,
+		},
+		{
+			name: "within error rate",
+			policy: func(ctx context.Context, in Stream[Result[int]]) Stream[int] {
+				return StopAtErrorRate(ctx, in, 0.5, 4)
+			},
+			values:   []int{-1, 1, 2, 3, -2, 4, 5, 6},
+			expected: []int{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := NewContext(context.Background())
+			defer cancel(nil)
+
+			actual := collect(tt.policy(ctx, results(ctx, tt.values...)))
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+
+			var budgetErr *BudgetError
+			cause := context.Cause(ctx)
+			switch {
+			case tt.errs == 0 && cause != nil:
+				t.Errorf("expected the pipeline to keep running, but it stopped with %v", cause)
+			case tt.errs > 0 && !errors.As(cause, &budgetErr):
+				t.Errorf("expected a BudgetError, but received %v", cause)
+			case tt.errs > 0 && (len(budgetErr.Errors) != tt.errs || !errors.Is(cause, ErrTooManyErrors)):
+				t.Errorf("expected %v errors wrapping ErrTooManyErrors, but received %v", tt.errs, cause)
+			}
+		})
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values, errs := RouteErrors(ctx, results(ctx, 1, -1, 2, -2, 3))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var errMessages []string
+	go func() {
+		defer wg.Done()
+		for err := range errs {
+			errMessages = append(errMessages, err.Error())
+		}
+	}()
+
+	expected := []int{1, 2, 3}
+	if actual := collect(values); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	wg.Wait()
+	expectedErrs := []string{"negative value: -1", "negative value: -2"}
+	if !reflect.DeepEqual(errMessages, expectedErrs) {
+		t.Errorf("expected %v, but received %v", expectedErrs, errMessages)
+	}
+}
+
+func TestStopAtErrorRateArguments(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   float64
+		window int
+	}{
+		{name: "zero window", rate: 0.5, window: 0},
+		{name: "zero rate", rate: 0, window: 4},
+		{name: "negative rate", rate: -1, window: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := NewContext(context.Background())
+			defer cancel(nil)
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected StopAtErrorRate(%v, %v) to panic", tt.rate, tt.window)
+				}
+			}()
+			StopAtErrorRate(ctx, results(ctx), tt.rate, tt.window)
+		})
+	}
+}
+
+func TestBudgetErrorWithoutErrors(t *testing.T) {
+	err := &BudgetError{}
+	if actual := err.Error(); actual != ErrTooManyErrors.Error() {
+		t.Errorf("expected %q, but received %q", ErrTooManyErrors, actual)
+	}
+}
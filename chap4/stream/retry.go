@@ -0,0 +1,167 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes how long to wait before the next attempt given the
+// previous wait, which is zero before the first retry.
+type Backoff interface {
+	Next(prev time.Duration) time.Duration
+}
+
+// ConstantBackoff always waits the same duration.
+type ConstantBackoff time.Duration
+
+func (b ConstantBackoff) Next(time.Duration) time.Duration {
+	return time.Duration(b)
+}
+
+func (b ConstantBackoff) validate() error {
+	if b <= 0 {
+		return fmt.Errorf("ConstantBackoff must be positive, got %v", time.Duration(b))
+	}
+	return nil
+}
+
+// ExponentialBackoff starts at Base and multiplies the wait by Factor (2
+// if unset) on every retry, up to Max. A zero Max leaves the wait uncapped.
+type ExponentialBackoff struct {
+	Base, Max time.Duration
+	Factor    float64
+}
+
+func (b ExponentialBackoff) Next(prev time.Duration) time.Duration {
+	if prev == 0 {
+		return capWait(b.Base, b.Max)
+	}
+	factor := b.Factor
+	if factor == 0 {
+		factor = 2
+	}
+	next := float64(prev) * factor
+	if next >= math.MaxInt64 {
+		return capWait(math.MaxInt64, b.Max)
+	}
+	return capWait(time.Duration(next), b.Max)
+}
+
+func (b ExponentialBackoff) validate() error {
+	if b.Base <= 0 {
+		return fmt.Errorf("ExponentialBackoff Base must be positive, got %v", b.Base)
+	}
+	if b.Factor != 0 && b.Factor < 1 {
+		return fmt.Errorf("ExponentialBackoff Factor must be at least 1, got %v", b.Factor)
+	}
+	if b.Max != 0 && b.Max < b.Base {
+		return fmt.Errorf("ExponentialBackoff Max %v is below Base %v", b.Max, b.Base)
+	}
+	return nil
+}
+
+// DecorrelatedJitterBackoff waits a random duration between Base and three
+// times the previous wait, up to Max. A zero Max leaves the wait uncapped.
+type DecorrelatedJitterBackoff struct {
+	Base, Max time.Duration
+}
+
+func (b DecorrelatedJitterBackoff) Next(prev time.Duration) time.Duration {
+	upper := time.Duration(math.MaxInt64 - 1)
+	if prev <= math.MaxInt64/3 {
+		upper = max(prev*3, b.Base)
+	}
+	return capWait(b.Base+time.Duration(rand.Int63n(int64(upper-b.Base)+1)), b.Max)
+}
+
+func (b DecorrelatedJitterBackoff) validate() error {
+	if b.Base <= 0 {
+		return fmt.Errorf("DecorrelatedJitterBackoff Base must be positive, got %v", b.Base)
+	}
+	if b.Max != 0 && b.Max < b.Base {
+		return fmt.Errorf("DecorrelatedJitterBackoff Max %v is below Base %v", b.Max, b.Base)
+	}
+	return nil
+}
+
+// capWait caps wait at limit unless limit is zero.
+func capWait(wait, limit time.Duration) time.Duration {
+	if limit > 0 && wait > limit {
+		return limit
+	}
+	return wait
+}
+
+// RetryPolicy configures Retry.
+type RetryPolicy struct {
+	Backoff Backoff
+	// MaxAttempts caps the attempts per element, including the first one.
+	MaxAttempts int
+	// Retryable reports whether an error is worth another attempt. Every
+	// error is retried if it is nil.
+	Retryable func(error) bool
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts <= 1 {
+		return nil
+	}
+	if p.Backoff == nil {
+		return fmt.Errorf("RetryPolicy allows %d attempts without a Backoff", p.MaxAttempts)
+	}
+	if b, ok := p.Backoff.(interface{ validate() error }); ok {
+		return b.validate()
+	}
+	return nil
+}
+
+// Retry applies fn to every element of in, retrying failed attempts as
+// policy allows. The ctx passed to fn is the stage context, and canceling
+// it interrupts a backoff wait. Elements that still fail are emitted with
+// their last error. Retry panics if policy allows retries without a
+// Backoff or with a Backoff that would never wait.
+func Retry[T, U any](
+	ctx context.Context,
+	in Stream[T],
+	fn func(context.Context, T) (U, error),
+	policy RetryPolicy,
+) Stream[Result[U]] {
+	if err := policy.validate(); err != nil {
+		panic("stream: " + err.Error())
+	}
+	return Map(ctx, in, func(v T) Result[U] {
+		u, err := retry(ctx, v, fn, policy)
+		return Result[U]{Value: u, Error: err}
+	})
+}
+
+func retry[T, U any](
+	ctx context.Context,
+	v T,
+	fn func(context.Context, T) (U, error),
+	policy RetryPolicy,
+) (U, error) {
+	var wait time.Duration
+	for attempt := 1; ; attempt++ {
+		u, err := fn(ctx, v)
+		if err == nil {
+			return u, nil
+		}
+		if policy.Retryable != nil && !policy.Retryable(err) {
+			return u, err
+		}
+		if attempt >= policy.MaxAttempts {
+			return u, fmt.Errorf("after %d attempts: %w", attempt, err)
+		}
+
+		wait = policy.Backoff.Next(wait)
+		select {
+		case <-ctx.Done():
+			return u, context.Cause(ctx)
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type statusError int
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("status %d", int(err))
+}
+
+// flakyServer answers 503 to the first failures requests and code after.
+func flakyServer(failures int32, code int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(code)
+	}))
+	return server, &requests
+}
+
+func checkStatus(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.StatusCode, statusError(resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func retryable(err error) bool {
+	var status statusError
+	return errors.As(err, &status) && status >= 500
+}
+
+func TestRetry(t *testing.T) {
+	backoffs := map[string]Backoff{
+		"constant":    ConstantBackoff(time.Millisecond),
+		"exponential": ExponentialBackoff{Base: time.Millisecond, Max: 4 * time.Millisecond},
+		"jitter":      DecorrelatedJitterBackoff{Base: time.Millisecond, Max: 4 * time.Millisecond},
+	}
+
+	tests := []struct {
+		name     string
+		failures int32
+		code     int
+		expected int
+		err      bool
+		requests int32
+	}{
+		{name: "recovers", failures: 2, code: http.StatusOK, expected: http.StatusOK, requests: 3},
+		{name: "gives up", failures: 10, code: http.StatusOK, expected: http.StatusServiceUnavailable, err: true, requests: 4},
+		{name: "not retryable", failures: 0, code: http.StatusNotFound, expected: http.StatusNotFound, err: true, requests: 1},
+	}
+
+	for backoffName, backoff := range backoffs {
+		for _, tt := range tests {
+			t.Run(backoffName+"/"+tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				server, requests := flakyServer(tt.failures, tt.code)
+				defer server.Close()
+
+				policy := RetryPolicy{Backoff: backoff, MaxAttempts: 4, Retryable: retryable}
+				r := <-Retry(ctx, Generate(ctx, server.URL), checkStatus, policy)
+				if r.Value != tt.expected || (r.Error != nil) != tt.err {
+					t.Errorf("expected %v (error: %v), but received %v (%v)", tt.expected, tt.err, r.Value, r.Error)
+				}
+				if n := requests.Load(); n != tt.requests {
+					t.Errorf("expected %v requests, but received %v", tt.requests, n)
+				}
+			})
+		}
+	}
+}
+
+func TestRetryCancelInterruptsBackoff(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	server, _ := flakyServer(10, http.StatusOK)
+	defer server.Close()
+
+	errStop := errors.New("stop")
+	time.AfterFunc(20*time.Millisecond, func() { cancel(errStop) })
+
+	start := time.Now()
+	policy := RetryPolicy{Backoff: ConstantBackoff(time.Hour), MaxAttempts: 3}
+	_, err := retry(ctx, server.URL, checkStatus, policy)
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected %v, but received %v", errStop, err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected cancel to interrupt the backoff, but it took %v", took)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	exponential := ExponentialBackoff{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	var wait time.Duration
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		wait = exponential.Next(wait)
+		waits = append(waits, wait)
+	}
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i := range expected {
+		if waits[i] != expected[i] {
+			t.Errorf("exponential: expected %v, but received %v", expected, waits)
+			break
+		}
+	}
+
+	jitter := DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	wait = 0
+	for i := 0; i < 100; i++ {
+		prev := wait
+		wait = jitter.Next(prev)
+		if wait < jitter.Base || wait > jitter.Max || wait > max(3*prev, jitter.Base) {
+			t.Fatalf("jitter: %v out of range after %v", wait, prev)
+		}
+	}
+}
+
+func TestBackoffUncapped(t *testing.T) {
+	exponential := ExponentialBackoff{Base: 10 * time.Millisecond}
+	if wait := exponential.Next(exponential.Next(0)); wait != 20*time.Millisecond {
+		t.Errorf("exponential: expected 20ms without a Max, but received %v", wait)
+	}
+	if wait := exponential.Next(time.Duration(math.MaxInt64 / 2)); wait <= 0 {
+		t.Errorf("exponential: expected a huge wait not to overflow, but received %v", wait)
+	}
+
+	jitter := DecorrelatedJitterBackoff{Base: 10 * time.Millisecond}
+	if wait := jitter.Next(time.Hour); wait < jitter.Base {
+		t.Errorf("jitter: expected at least %v without a Max, but received %v", jitter.Base, wait)
+	}
+	if wait := jitter.Next(time.Duration(math.MaxInt64 / 2)); wait < jitter.Base {
+		t.Errorf("jitter: expected a huge wait not to overflow, but received %v", wait)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+	}{
+		{name: "nil backoff", policy: RetryPolicy{MaxAttempts: 3}},
+		{name: "zero constant", policy: RetryPolicy{Backoff: ConstantBackoff(0), MaxAttempts: 5}},
+		{name: "zero exponential base", policy: RetryPolicy{Backoff: ExponentialBackoff{Max: time.Second}, MaxAttempts: 3}},
+		{name: "shrinking factor", policy: RetryPolicy{Backoff: ExponentialBackoff{Base: time.Millisecond, Factor: 0.5}, MaxAttempts: 3}},
+		{name: "exponential max below base", policy: RetryPolicy{Backoff: ExponentialBackoff{Base: time.Second, Max: time.Millisecond}, MaxAttempts: 3}},
+		{name: "zero jitter base", policy: RetryPolicy{Backoff: DecorrelatedJitterBackoff{Max: time.Second}, MaxAttempts: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected Retry to panic for %+v", tt.policy)
+				}
+			}()
+			Retry(ctx, Generate(ctx, 1), func(context.Context, int) (int, error) { return 0, nil }, tt.policy)
+		})
+	}
+}
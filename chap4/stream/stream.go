@@ -0,0 +1,192 @@
+// Package stream provides typed versions of the pipeline stages from chap4.
+//
+// Every stage takes a context.Context instead of a done channel. A stage
+// stops and closes its output as soon as the context is canceled, so the
+// reason a pipeline stopped can be read with context.Cause.
+//
+// Stages start their goroutines with Go, so a panic in one stage fails the
+// pipeline with a *CrashReport instead of killing the process. Under a
+// context that is neither a pipeline's nor has a crash handler, a panic
+// still kills the process.
+package stream
+
+import "context"
+
+// Stream is a receive-only channel of T produced by a pipeline stage.
+type Stream[T any] <-chan T
+
+// Generate emits values in order and then closes the stream.
+func Generate[T any](ctx context.Context, values ...T) Stream[T] {
+	valueStream := make(chan T, len(values))
+	Go(ctx, "Generate", func(g *Goroutine) {
+		defer close(valueStream)
+		for _, v := range values {
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- v:
+			}
+		}
+	})
+	return valueStream
+}
+
+// Map applies fn to every element of in.
+func Map[T, U any](ctx context.Context, in Stream[T], fn func(T) U) Stream[U] {
+	mappedStream := make(chan U)
+	Go(ctx, "Map", func(g *Goroutine) {
+		defer close(mappedStream)
+		for v := range in {
+			g.Working(v)
+			select {
+			case <-ctx.Done():
+				return
+			case mappedStream <- fn(v):
+			}
+		}
+	})
+	return mappedStream
+}
+
+// Filter emits only the elements of in for which keep returns true.
+func Filter[T any](ctx context.Context, in Stream[T], keep func(T) bool) Stream[T] {
+	filteredStream := make(chan T)
+	Go(ctx, "Filter", func(g *Goroutine) {
+		defer close(filteredStream)
+		for v := range in {
+			g.Working(v)
+			if !keep(v) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case filteredStream <- v:
+			}
+		}
+	})
+	return filteredStream
+}
+
+// Take emits at most num elements of in.
+func Take[T any](ctx context.Context, in Stream[T], num int) Stream[T] {
+	takeStream := make(chan T)
+	Go(ctx, "Take", func(g *Goroutine) {
+		defer close(takeStream)
+		for i := 0; i < num; i++ {
+			var v T
+			select {
+			case <-ctx.Done():
+				return
+			case maybeV, ok := <-in:
+				if !ok {
+					return
+				}
+				v = maybeV
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case takeStream <- v:
+			}
+		}
+	})
+	return takeStream
+}
+
+// Repeat emits values over and over until ctx is canceled.
+func Repeat[T any](ctx context.Context, values ...T) Stream[T] {
+	valueStream := make(chan T)
+	Go(ctx, "Repeat", func(g *Goroutine) {
+		defer close(valueStream)
+		if len(values) == 0 {
+			return
+		}
+		for {
+			for _, v := range values {
+				select {
+				case <-ctx.Done():
+					return
+				case valueStream <- v:
+				}
+			}
+		}
+	})
+	return valueStream
+}
+
+// RepeatFn emits the result of calling fn until ctx is canceled.
+func RepeatFn[T any](ctx context.Context, fn func() T) Stream[T] {
+	valueStream := make(chan T)
+	Go(ctx, "RepeatFn", func(g *Goroutine) {
+		defer close(valueStream)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- fn():
+			}
+		}
+	})
+	return valueStream
+}
+
+// OrDone forwards the elements of c until c is closed or ctx is canceled.
+func OrDone[T any](ctx context.Context, c Stream[T]) Stream[T] {
+	valStream := make(chan T)
+	Go(ctx, "OrDone", func(g *Goroutine) {
+		defer close(valStream)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-c:
+				if !ok {
+					return
+				}
+				select {
+				case valStream <- v:
+				case <-ctx.Done():
+				}
+			}
+		}
+	})
+	return valStream
+}
+
+// MapErr applies fn to every element of in. The first error returned by fn
+// stops the pipeline with that error as its cause. MapErr panics unless ctx
+// comes from NewContext or DoneContext.
+func MapErr[T, U any](ctx context.Context, in Stream[T], fn func(T) (U, error)) Stream[U] {
+	mustFail(ctx, "MapErr")
+	mappedStream := make(chan U)
+	Go(ctx, "MapErr", func(g *Goroutine) {
+		defer close(mappedStream)
+		for v := range OrDone(ctx, in) {
+			g.Working(v)
+			u, err := fn(v)
+			if err != nil {
+				Fail(ctx, err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case mappedStream <- u:
+			}
+		}
+	})
+	return mappedStream
+}
+
+// recv receives the next element of in. It reports false once in is closed
+// or ctx is canceled.
+func recv[T any](ctx context.Context, in Stream[T]) (T, bool) {
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	case v, ok := <-in:
+		return v, ok
+	}
+}
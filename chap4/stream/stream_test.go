@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func collect[T any](s Stream[T]) []T {
+	var vals []T
+	for v := range s {
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+func TestIntPipeline(t *testing.T) {
+	multiply := func(m int) func(int) int { return func(v int) int { return v * m } }
+	add := func(a int) func(int) int { return func(v int) int { return v + a } }
+
+	tests := []struct {
+		name     string
+		pipeline func(ctx context.Context) Stream[int]
+		expected []int
+	}{
+		{
+			name: "add(multiply(ints, 2), 1)",
+			pipeline: func(ctx context.Context) Stream[int] {
+				return Map(ctx, Map(ctx, Generate(ctx, 1, 2, 3, 4), multiply(2)), add(1))
+			},
+			expected: []int{3, 5, 7, 9},
+		},
+		{
+			name: "multiply(add(multiply(ints, 2), 1), 2)",
+			pipeline: func(ctx context.Context) Stream[int] {
+				intStream := Generate(ctx, 1, 2, 3, 4)
+				return Map(ctx, Map(ctx, Map(ctx, intStream, multiply(2)), add(1)), multiply(2))
+			},
+			expected: []int{6, 10, 14, 18},
+		},
+		{
+			name: "take(repeat(1), 10)",
+			pipeline: func(ctx context.Context) Stream[int] {
+				return Take(ctx, Repeat(ctx, 1), 10)
+			},
+			expected: []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name: "take(repeatFn(counter), 5)",
+			pipeline: func(ctx context.Context) Stream[int] {
+				n := 0
+				return Take(ctx, RepeatFn(ctx, func() int { n++; return n }), 5)
+			},
+			expected: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name: "filter(generate, even)",
+			pipeline: func(ctx context.Context) Stream[int] {
+				even := func(v int) bool { return v%2 == 0 }
+				return Filter(ctx, Generate(ctx, 1, 2, 3, 4, 5, 6), even)
+			},
+			expected: []int{2, 4, 6},
+		},
+		{
+			name: "take more than generated",
+			pipeline: func(ctx context.Context) Stream[int] {
+				return Take(ctx, Generate(ctx, 1, 2), 5)
+			},
+			expected: []int{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if actual := collect(tt.pipeline(ctx)); !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStringPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		num      int
+		expected string
+	}{
+		{name: "repeats", values: []string{"I", "am."}, num: 5, expected: "Iam.Iam.I"},
+		{name: "single value", values: []string{"a"}, num: 3, expected: "aaa"},
+		{name: "take nothing", values: []string{"a"}, num: 0, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var message strings.Builder
+			for token := range Take(ctx, Repeat(ctx, tt.values...), tt.num) {
+				message.WriteString(token)
+			}
+			if actual := message.String(); actual != tt.expected {
+				t.Errorf("expected %q, but received %q", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package streamtest helps test concurrent stages. It drives stages with a
+// fake clock instead of real sleeps, checks that a test leaves no
+// goroutines behind, and can perturb interleavings with runtime.Gosched
+// calls drawn from a seeded source, so they are perturbed per seed. The Go
+// scheduler is not deterministic, so a seed does not replay an
+// interleaving.
+package streamtest
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+	"github.com/cipepser/go-concurrency/chap4/stream/internal/goid"
+)
+
+// Settle is how long, in real time, helpers let goroutines run before they
+// decide that a stage is idle and advance the fake clock.
+var Settle = 10 * time.Millisecond
+
+// Patience is how long, in real time, helpers wait for a stage that has
+// no fake timer to advance to before they fail the test.
+var Patience = time.Second
+
+// NewClock returns a fake clock for a test. Every clock starts at the same
+// instant so failures print the same times from run to run.
+func NewClock() *clock.Fake {
+	return clock.NewFake(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// NoLeaks fails t if goroutines started after it was called are still
+// running once the test and its deferred calls have finished. Call it
+// first thing in the test.
+func NoLeaks(t testing.TB) {
+	t.Helper()
+	before := goroutines()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for {
+			var leaked []string
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("%v goroutines leaked:\n\n%v", len(leaked), strings.Join(leaked, "\n\n"))
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+// goroutines returns the stack of every running goroutine by its ID.
+func goroutines() map[int64]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := make(map[int64]string)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		id, ok := goid.Parse(stack)
+		if ok {
+			stacks[id] = string(stack)
+		}
+	}
+	return stacks
+}
+
+// EventuallyEmits fails t unless s emits want before clk has advanced by
+// within. Whenever the stage goes idle it moves clk to the next timer, so
+// the test takes as much virtual time as the stage needs and little real
+// time. A stage that has not armed any timer yet is given Patience, in
+// real time, to do so. Other values received from s are discarded.
+func EventuallyEmits[T comparable](t testing.TB, clk *clock.Fake, s <-chan T, want T, within time.Duration) {
+	t.Helper()
+	deadline := clk.Now().Add(within)
+	idleSince := time.Now()
+	for {
+		select {
+		case v, ok := <-s:
+			if !ok {
+				t.Fatalf("expected %v, but the stream closed", want)
+			}
+			if v == want {
+				return
+			}
+			idleSince = time.Now()
+			continue
+		case <-time.After(Settle):
+		}
+
+		next, ok := clk.Next()
+		switch {
+		case ok && !next.After(deadline):
+			clk.Advance(next.Sub(clk.Now()))
+			idleSince = time.Now()
+		case ok || time.Since(idleSince) >= Patience:
+			t.Fatalf("expected %v within %v, but nothing was received", want, within)
+		}
+	}
+}
+
+// Seed returns the seed for a Scheduler: STREAMTEST_SEED if it is set,
+// otherwise the current time. It logs the seed so a failing test can be
+// rerun with the same perturbation through STREAMTEST_SEED, which makes the
+// failing interleaving more likely but does not replay it.
+func Seed(t testing.TB) int64 {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	if s := os.Getenv("STREAMTEST_SEED"); s != "" {
+		var err error
+		if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			t.Fatalf("invalid STREAMTEST_SEED %q: %v", s, err)
+		}
+	}
+	t.Logf("STREAMTEST_SEED=%v", seed)
+	return seed
+}
+
+// Scheduler decides, from a seeded random source, when to yield the
+// processor at a channel operation.
+type Scheduler struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewScheduler returns a Scheduler whose yields are perturbed per seed: the
+// same seed gives the same number of Gosched calls at each operation, but
+// not the same interleaving.
+func NewScheduler(seed int64) *Scheduler {
+	return &Scheduler{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Yield calls runtime.Gosched between zero and three times.
+func (s *Scheduler) Yield() {
+	s.mu.Lock()
+	n := s.rnd.Intn(4)
+	s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		runtime.Gosched()
+	}
+}
+
+// Perturb forwards in, yielding through s before every receive and every
+// send so the stages on either side see a different interleaving for each
+// seed.
+func Perturb[T any](ctx context.Context, s *Scheduler, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			s.Yield()
+			var v T
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+			s.Yield()
+			select {
+			case <-ctx.Done():
+				return
+			case out <- v:
+			}
+		}
+	}()
+	return out
+}
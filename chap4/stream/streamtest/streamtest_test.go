@@ -0,0 +1,183 @@
+package streamtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder stands in for the testing.TB of a test that is expected to fail.
+type recorder struct {
+	testing.TB
+	mu       sync.Mutex
+	failed   bool
+	cleanups []func()
+}
+
+func (r *recorder) Helper()                           {}
+func (r *recorder) Logf(string, ...interface{})       {}
+func (r *recorder) Cleanup(f func())                  { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(string, ...interface{})     { r.fail() }
+func (r *recorder) Fatalf(f string, a ...interface{}) { r.fail(); runtime.Goexit() }
+
+func (r *recorder) fail() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = true
+}
+
+func (r *recorder) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// run calls f as a test body on its own goroutine, then its cleanups.
+func (r *recorder) run(f func(testing.TB)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestNoLeaks(t *testing.T) {
+	tests := []struct {
+		name   string
+		stop   bool
+		failed bool
+	}{
+		{name: "stopped", stop: true, failed: false},
+		{name: "leaked", stop: false, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := make(chan struct{})
+			defer close(block)
+
+			r := &recorder{}
+			r.run(func(t testing.TB) {
+				NoLeaks(t)
+				stop := make(chan struct{})
+				go func() {
+					select {
+					case <-stop:
+					case <-block:
+					}
+				}()
+				if tt.stop {
+					close(stop)
+				}
+			})
+			if r.Failed() != tt.failed {
+				t.Errorf("expected failed to be %v, but received %v", tt.failed, r.Failed())
+			}
+		})
+	}
+}
+
+func TestEventuallyEmits(t *testing.T) {
+	tests := []struct {
+		within time.Duration
+		failed bool
+	}{
+		{within: 2 * time.Hour, failed: false},
+		{within: 30 * time.Minute, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.within), func(t *testing.T) {
+			clk := NewClock()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := make(chan int)
+			go func() {
+				for i := 0; ; i++ {
+					select {
+					case <-ctx.Done():
+						return
+					case <-clk.After(time.Hour):
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case s <- i:
+					}
+				}
+			}()
+
+			r := &recorder{}
+			start := time.Now()
+			r.run(func(t testing.TB) { EventuallyEmits(t, clk, s, 1, tt.within) })
+			if r.Failed() != tt.failed {
+				t.Errorf("expected failed to be %v, but received %v", tt.failed, r.Failed())
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected virtual hours to take little real time, but took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestPerturb(t *testing.T) {
+	NoLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < 100; i++ {
+			in <- i
+		}
+	}()
+
+	var actual, expected []int
+	for v := range Perturb(ctx, NewScheduler(Seed(t)), in) {
+		actual = append(actual, v)
+	}
+	for i := 0; i < 100; i++ {
+		expected = append(expected, i)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestEventuallyEmitsSlowStage(t *testing.T) {
+	clk := NewClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The stage takes several Settle windows of real time before it arms
+	// its timer.
+	s := make(chan int)
+	go func() {
+		time.Sleep(5 * Settle)
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(time.Hour):
+		}
+		select {
+		case <-ctx.Done():
+		case s <- 1:
+		}
+	}()
+
+	r := &recorder{}
+	r.run(func(t testing.TB) { EventuallyEmits(t, clk, s, 1, 2*time.Hour) })
+	if r.Failed() {
+		t.Error("expected a slow stage to be waited for")
+	}
+}
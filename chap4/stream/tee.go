@@ -0,0 +1,28 @@
+package stream
+
+import "context"
+
+// Tee sends every element of in to both outputs. Both outputs must be read
+// for the stage to make progress.
+func Tee[T any](ctx context.Context, in Stream[T]) (_, _ Stream[T]) {
+	out1 := make(chan T)
+	out2 := make(chan T)
+	Go(ctx, "Tee", func(g *Goroutine) {
+		defer close(out1)
+		defer close(out2)
+		for val := range OrDone(ctx, in) {
+			var out1, out2 = out1, out2
+			for i := 0; i < 2; i++ {
+				select {
+				case <-ctx.Done():
+					return
+				case out1 <- val:
+					out1 = nil
+				case out2 <- val:
+					out2 = nil
+				}
+			}
+		}
+	})
+	return out1, out2
+}
@@ -0,0 +1,24 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTee(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out1, out2 := Tee(ctx, Take(ctx, Repeat(ctx, 1, 2), 4))
+	var vals1, vals2 []int
+	for val1 := range out1 {
+		vals1 = append(vals1, val1)
+		vals2 = append(vals2, <-out2)
+	}
+
+	expected := []int{1, 2, 1, 2}
+	if !reflect.DeepEqual(vals1, expected) || !reflect.DeepEqual(vals2, expected) {
+		t.Errorf("expected %v on both outputs, but received %v and %v", expected, vals1, vals2)
+	}
+}
@@ -0,0 +1,201 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// TeeMode decides what TeeN does with an output whose reader falls behind.
+type TeeMode int
+
+const (
+	// TeeBlock waits for the reader, holding back every other output and
+	// the upstream stage, like Tee.
+	TeeBlock TeeMode = iota
+	// TeeDropNewest discards the incoming element when the output buffer
+	// is full.
+	TeeDropNewest
+	// TeeDropOldest discards the oldest buffered element to make room for
+	// the incoming one.
+	TeeDropOldest
+	// TeeDisconnect waits up to Timeout for the reader and then closes the
+	// output for good.
+	TeeDisconnect
+)
+
+// TeePolicy configures a single TeeN output.
+type TeePolicy struct {
+	Mode TeeMode
+	// Buffer is the capacity of the output channel. The drop modes always
+	// use at least 1.
+	Buffer int
+	// Timeout is how long TeeDisconnect waits for a stalled reader. It must
+	// be positive for TeeDisconnect.
+	Timeout time.Duration
+}
+
+// TeeOutputs holds the outputs of TeeN along with per-output counters.
+type TeeOutputs[T any] struct {
+	Streams      []Stream[T]
+	dropped      []atomic.Uint64
+	disconnected []atomic.Bool
+}
+
+// Dropped reports how many elements were not delivered to output i.
+// Elements that arrive after an output was disconnected count as dropped.
+func (o *TeeOutputs[T]) Dropped(i int) uint64 {
+	return o.dropped[i].Load()
+}
+
+// Disconnected reports whether output i was closed by TeeDisconnect.
+func (o *TeeOutputs[T]) Disconnected(i int) bool {
+	return o.disconnected[i].Load()
+}
+
+// TeeN sends every element of in to n outputs that all share policy. It
+// panics on the same policies as TeeEach.
+func TeeN[T any](ctx context.Context, in Stream[T], n int, policy TeePolicy) *TeeOutputs[T] {
+	policies := make([]TeePolicy, n)
+	for i := range policies {
+		policies[i] = policy
+	}
+	return TeeEach(ctx, in, policies...)
+}
+
+// TeeEach sends every element of in to one output per policy. Outputs with
+// a drop policy never hold back the others; blocking outputs are served in
+// whatever order their readers become ready. TeeEach panics if a
+// TeeDisconnect policy has no positive Timeout.
+func TeeEach[T any](ctx context.Context, in Stream[T], policies ...TeePolicy) *TeeOutputs[T] {
+	for i, p := range policies {
+		if p.Mode == TeeDisconnect && p.Timeout <= 0 {
+			panic(fmt.Sprintf("stream: TeeDisconnect timeout of output %d must be positive, got %v", i, p.Timeout))
+		}
+	}
+	outs := make([]chan T, len(policies))
+	o := &TeeOutputs[T]{
+		Streams:      make([]Stream[T], len(policies)),
+		dropped:      make([]atomic.Uint64, len(policies)),
+		disconnected: make([]atomic.Bool, len(policies)),
+	}
+	for i, p := range policies {
+		size := p.Buffer
+		if size < 1 && (p.Mode == TeeDropNewest || p.Mode == TeeDropOldest) {
+			size = 1
+		}
+		outs[i] = make(chan T, size)
+		o.Streams[i] = outs[i]
+	}
+
+	disconnect := func(i int) {
+		o.disconnected[i].Store(true)
+		o.dropped[i].Add(1)
+		close(outs[i])
+	}
+
+	// sendAll delivers v to every output in pending, disconnecting the ones
+	// whose policy timeout passes first.
+	sendAll := func(pending []int, v T) bool {
+		start := time.Now()
+		for len(pending) > 0 {
+			cases := make([]reflect.SelectCase, 0, len(pending)+2)
+			for _, i := range pending {
+				cases = append(cases, reflect.SelectCase{
+					Dir:  reflect.SelectSend,
+					Chan: reflect.ValueOf(outs[i]),
+					Send: reflect.ValueOf(&v).Elem(),
+				})
+			}
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(ctx.Done()),
+			})
+
+			var deadline time.Time
+			for _, i := range pending {
+				if policies[i].Mode != TeeDisconnect {
+					continue
+				}
+				if d := start.Add(policies[i].Timeout); deadline.IsZero() || d.Before(deadline) {
+					deadline = d
+				}
+			}
+			if !deadline.IsZero() {
+				cases = append(cases, reflect.SelectCase{
+					Dir:  reflect.SelectRecv,
+					Chan: reflect.ValueOf(time.After(time.Until(deadline))),
+				})
+			}
+
+			chosen, _, _ := reflect.Select(cases)
+			switch {
+			case chosen < len(pending):
+				pending = append(pending[:chosen], pending[chosen+1:]...)
+			case chosen == len(pending):
+				return false
+			default:
+				now := time.Now()
+				remaining := pending[:0]
+				for _, i := range pending {
+					if policies[i].Mode == TeeDisconnect && !now.Before(start.Add(policies[i].Timeout)) {
+						disconnect(i)
+						continue
+					}
+					remaining = append(remaining, i)
+				}
+				pending = remaining
+			}
+		}
+		return true
+	}
+
+	Go(ctx, "TeeN", func(g *Goroutine) {
+		defer func() {
+			for i := range outs {
+				if !o.disconnected[i].Load() {
+					close(outs[i])
+				}
+			}
+		}()
+
+		for v := range OrDone(ctx, in) {
+			var pending []int
+			for i, p := range policies {
+				if o.disconnected[i].Load() {
+					o.dropped[i].Add(1)
+					continue
+				}
+				switch p.Mode {
+				case TeeDropNewest:
+					select {
+					case outs[i] <- v:
+					default:
+						o.dropped[i].Add(1)
+					}
+				case TeeDropOldest:
+					for sent := false; !sent; {
+						select {
+						case outs[i] <- v:
+							sent = true
+						default:
+							select {
+							case <-outs[i]:
+								o.dropped[i].Add(1)
+							default:
+							}
+						}
+					}
+				default:
+					pending = append(pending, i)
+				}
+			}
+			if !sendAll(pending, v) {
+				return
+			}
+		}
+	})
+	return o
+}
@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTeeNBlock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	o := TeeN(ctx, Generate(ctx, 1, 2, 3), 3, TeePolicy{Mode: TeeBlock})
+	expected := []int{1, 2, 3}
+	for i := range expected {
+		// Read the outputs in reverse to make sure blocking outputs are not
+		// served in a fixed order.
+		for j := len(o.Streams) - 1; j >= 0; j-- {
+			if v := <-o.Streams[j]; v != expected[i] {
+				t.Errorf("output %v: expected %v, but received %v", j, expected[i], v)
+			}
+		}
+	}
+}
+
+func TestTeeNSlowConsumer(t *testing.T) {
+	values := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		name         string
+		policy       TeePolicy
+		expected     []int
+		dropped      uint64
+		disconnected bool
+	}{
+		{
+			name:     "drop newest",
+			policy:   TeePolicy{Mode: TeeDropNewest, Buffer: 3},
+			expected: []int{1, 2, 3},
+			dropped:  7,
+		},
+		{
+			name:     "drop oldest",
+			policy:   TeePolicy{Mode: TeeDropOldest, Buffer: 3},
+			expected: []int{8, 9, 10},
+			dropped:  7,
+		},
+		{
+			name:         "disconnect",
+			policy:       TeePolicy{Mode: TeeDisconnect, Buffer: 2, Timeout: 10 * time.Millisecond},
+			expected:     []int{1, 2},
+			dropped:      8,
+			disconnected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Output 0 keeps up while output 1 is not read until the
+			// upstream is exhausted.
+			o := TeeEach(ctx, Generate(ctx, values...), TeePolicy{Mode: TeeBlock}, tt.policy)
+			if fast := collect(o.Streams[0]); !reflect.DeepEqual(fast, values) {
+				t.Errorf("fast output: expected %v, but received %v", values, fast)
+			}
+			if slow := collect(o.Streams[1]); !reflect.DeepEqual(slow, tt.expected) {
+				t.Errorf("slow output: expected %v, but received %v", tt.expected, slow)
+			}
+			if dropped := o.Dropped(1); dropped != tt.dropped {
+				t.Errorf("expected %v dropped, but received %v", tt.dropped, dropped)
+			}
+			if o.Dropped(0) != 0 {
+				t.Errorf("expected nothing dropped on the fast output, but received %v", o.Dropped(0))
+			}
+			if o.Disconnected(1) != tt.disconnected {
+				t.Errorf("expected disconnected to be %v", tt.disconnected)
+			}
+		})
+	}
+}
+
+func TestTeeDisconnectWithoutTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Without a timeout a ready reader would race an expired deadline.
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected TeeN to panic")
+		}
+	}()
+	TeeN(ctx, Generate(ctx, 1), 2, TeePolicy{Mode: TeeDisconnect})
+}
@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cipepser/go-concurrency/chap5/ratelimit"
+)
+
+// Throttle forwards the elements of in no faster than limiter allows. If
+// limiter fails to wait the pipeline stops with its error as the cause.
+// Throttle panics unless ctx comes from NewContext or DoneContext.
+func Throttle[T any](ctx context.Context, in Stream[T], limiter ratelimit.RateLimiter) Stream[T] {
+	mustFail(ctx, "Throttle")
+	throttledStream := make(chan T)
+	Go(ctx, "Throttle", func(g *Goroutine) {
+		defer close(throttledStream)
+		for v := range OrDone(ctx, in) {
+			if err := limiter.Wait(ctx); err != nil {
+				Fail(ctx, err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case throttledStream <- v:
+			}
+		}
+	})
+	return throttledStream
+}
+
+// ThrottleKeyed forwards the elements of in no faster than the limiter for
+// their key allows. newLimiter is called once per key and its limiter is
+// kept until the stage ends, so a key keeps its rate across bursts. Keys
+// are throttled independently, so a slow key does not hold up the others
+// and the order of elements with different keys is not kept. The elements
+// of a key wait for its limiter in a backlog that grows without bound; a
+// key only has a goroutine while its backlog is not empty. Like Throttle,
+// ThrottleKeyed panics unless ctx comes from NewContext or DoneContext.
+func ThrottleKeyed[T any, K comparable](
+	ctx context.Context,
+	in Stream[T],
+	key func(T) K,
+	newLimiter func(K) ratelimit.RateLimiter,
+) Stream[T] {
+	mustFail(ctx, "ThrottleKeyed")
+	throttledStream := make(chan T)
+	Go(ctx, "ThrottleKeyed", func(g *Goroutine) {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(throttledStream)
+		}()
+
+		limiters := make(map[K]ratelimit.RateLimiter)
+		var mu sync.Mutex
+		backlogs := make(map[K][]T)
+		for v := range OrDone(ctx, in) {
+			g.Working(v)
+			k := key(v)
+			limiter, ok := limiters[k]
+			if !ok {
+				limiter = newLimiter(k)
+				limiters[k] = limiter
+			}
+
+			mu.Lock()
+			backlog, draining := backlogs[k]
+			backlogs[k] = append(backlog, v)
+			mu.Unlock()
+			if draining {
+				continue
+			}
+			wg.Add(1)
+			Go(ctx, "ThrottleKeyed", func(g *Goroutine) {
+				defer wg.Done()
+				for {
+					mu.Lock()
+					backlog := backlogs[k]
+					if len(backlog) == 0 {
+						delete(backlogs, k)
+						mu.Unlock()
+						return
+					}
+					v := backlog[0]
+					var zero T
+					backlog[0] = zero
+					backlogs[k] = backlog[1:]
+					mu.Unlock()
+
+					g.Working(v)
+					if err := limiter.Wait(ctx); err != nil {
+						Fail(ctx, err)
+						return
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case throttledStream <- v:
+					}
+				}
+			})
+		}
+	})
+	return throttledStream
+}
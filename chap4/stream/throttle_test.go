@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap5/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+func TestThrottle(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	// 2 bursts allowed, then 1 every 20ms by the faster limiter and 1 every
+	// 30ms by the slower one.
+	limiter := ratelimit.MultiLimiter(
+		rate.NewLimiter(ratelimit.Per(1, 20*time.Millisecond), 2),
+		rate.NewLimiter(ratelimit.Per(1, 30*time.Millisecond), 2),
+	)
+
+	start := time.Now()
+	actual := collect(Throttle(ctx, Generate(ctx, 1, 2, 3, 4, 5), limiter))
+	took := time.Since(start)
+
+	if expected := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if took < 80*time.Millisecond {
+		t.Errorf("expected the slower limiter to hold 3 elements for 90ms, but took %v", took)
+	}
+}
+
+func TestThrottleWaitError(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	// A limiter without burst can never let an element through.
+	collect(Throttle(ctx, Generate(ctx, 1), rate.NewLimiter(rate.Limit(1), 0)))
+	if cause := context.Cause(ctx); cause == nil || !strings.Contains(cause.Error(), "burst") {
+		t.Errorf("expected the limiter's burst error, but received %v", cause)
+	}
+}
+
+type tenantRequest struct {
+	tenant string
+	id     int
+}
+
+func TestThrottleKeyed(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	var mu sync.Mutex
+	created := map[string]int{}
+	newLimiter := func(tenant string) ratelimit.RateLimiter {
+		mu.Lock()
+		defer mu.Unlock()
+		created[tenant]++
+		if tenant == "noisy" {
+			return rate.NewLimiter(ratelimit.Per(1, time.Hour), 1)
+		}
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+
+	in := Generate(ctx,
+		tenantRequest{"noisy", 1},
+		tenantRequest{"noisy", 2},
+		tenantRequest{"quiet", 1},
+		tenantRequest{"quiet", 2},
+		tenantRequest{"quiet", 3},
+	)
+	throttled := ThrottleKeyed(ctx, in, func(r tenantRequest) string { return r.tenant }, newLimiter)
+
+	// The noisy tenant's second request waits an hour, but the quiet tenant
+	// still gets through.
+	var quiet int
+	for r := range Take(ctx, throttled, 4) {
+		if r.tenant == "quiet" {
+			quiet++
+		}
+	}
+	if quiet != 3 {
+		t.Errorf("expected 3 quiet requests, but received %v", quiet)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if expected := map[string]int{"noisy": 1, "quiet": 1}; !reflect.DeepEqual(created, expected) {
+		t.Errorf("expected one limiter per tenant, but received %v", created)
+	}
+}
+
+func TestThrottleKeyedBacklog(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	newLimiter := func(tenant string) ratelimit.RateLimiter {
+		if tenant == "noisy" {
+			return rate.NewLimiter(ratelimit.Per(1, time.Hour), 1)
+		}
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	in := Generate(ctx,
+		tenantRequest{"noisy", 1},
+		tenantRequest{"noisy", 2},
+		tenantRequest{"noisy", 3},
+		tenantRequest{"noisy", 4},
+		tenantRequest{"noisy", 5},
+		tenantRequest{"quiet", 1},
+	)
+	throttled := ThrottleKeyed(ctx, in, func(r tenantRequest) string { return r.tenant }, newLimiter)
+
+	// The noisy tenant's backlog queues up behind its limiter instead of
+	// blocking the quiet tenant.
+	expected := map[tenantRequest]bool{{"noisy", 1}: true, {"quiet", 1}: true}
+	for i := 0; i < len(expected); i++ {
+		select {
+		case r := <-throttled:
+			if !expected[r] {
+				t.Errorf("expected one of %v, but received %v", expected, r)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the quiet request not to wait for the noisy ones")
+		}
+	}
+}
+
+func TestThrottleKeyedIdleKeys(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	baseline := runtime.NumGoroutine()
+	in := make(chan tenantRequest)
+	defer close(in)
+	newLimiter := func(string) ratelimit.RateLimiter { return rate.NewLimiter(rate.Inf, 1) }
+	throttled := ThrottleKeyed(ctx, in, func(r tenantRequest) string { return r.tenant }, newLimiter)
+
+	for i := 0; i < 100; i++ {
+		in <- tenantRequest{fmt.Sprint("tenant", i), i}
+		<-throttled
+	}
+	// Only the stage and its OrDone are left once every backlog is empty.
+	waitForGoroutines(t, baseline+2)
+}
@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+// ErrTimeout is the cancellation cause of a pipeline stopped by Timeout.
+var ErrTimeout = errors.New("stream: timed out waiting for element")
+
+// Debounce emits an element only once in has been quiet for d after it.
+// The last element is emitted as soon as in closes.
+func Debounce[T any](ctx context.Context, in Stream[T], d time.Duration, clk clock.Clock) Stream[T] {
+	debouncedStream := make(chan T)
+	Go(ctx, "Debounce", func(g *Goroutine) {
+		defer close(debouncedStream)
+		var pending T
+		var timer clock.Timer
+		var quiet <-chan time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		emit := func() bool {
+			quiet = nil
+			select {
+			case <-ctx.Done():
+				return false
+			case debouncedStream <- pending:
+				return true
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					if quiet != nil {
+						emit()
+					}
+					return
+				}
+				pending = v
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = clk.NewTimer(d)
+				quiet = timer.C()
+			case <-quiet:
+				if !emit() {
+					return
+				}
+			}
+		}
+	})
+	return debouncedStream
+}
+
+// ThrottleFirst emits an element and then drops everything that arrives in
+// the following d.
+func ThrottleFirst[T any](ctx context.Context, in Stream[T], d time.Duration, clk clock.Clock) Stream[T] {
+	throttledStream := make(chan T)
+	Go(ctx, "ThrottleFirst", func(g *Goroutine) {
+		defer close(throttledStream)
+		var timer clock.Timer
+		var quiet <-chan time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			// Reopen the gate before looking at in, so an element that
+			// arrives right after the quiet period ends is not dropped.
+			select {
+			case <-quiet:
+				quiet = nil
+			default:
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-quiet:
+				quiet = nil
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if quiet != nil {
+					continue
+				}
+				timer = clk.NewTimer(d)
+				quiet = timer.C()
+				select {
+				case <-ctx.Done():
+					return
+				case throttledStream <- v:
+				}
+			}
+		}
+	})
+	return throttledStream
+}
+
+// Sample emits the latest element of in every d, skipping intervals in
+// which nothing new arrived.
+func Sample[T any](ctx context.Context, in Stream[T], d time.Duration, clk clock.Clock) Stream[T] {
+	sampledStream := make(chan T)
+	Go(ctx, "Sample", func(g *Goroutine) {
+		defer close(sampledStream)
+		ticker := clk.NewTicker(d)
+		defer ticker.Stop()
+
+		var latest T
+		fresh := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				latest, fresh = v, true
+			case <-ticker.C():
+				if !fresh {
+					continue
+				}
+				fresh = false
+				select {
+				case <-ctx.Done():
+					return
+				case sampledStream <- latest:
+				}
+			}
+		}
+	})
+	return sampledStream
+}
+
+// Timeout forwards the elements of in, stopping the pipeline with
+// ErrTimeout if the next element takes longer than d to arrive. Timeout
+// panics unless ctx comes from NewContext or DoneContext.
+func Timeout[T any](ctx context.Context, in Stream[T], d time.Duration, clk clock.Clock) Stream[T] {
+	mustFail(ctx, "Timeout")
+	timeoutStream := make(chan T)
+	Go(ctx, "Timeout", func(g *Goroutine) {
+		defer close(timeoutStream)
+		for {
+			timer := clk.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+				Fail(ctx, ErrTimeout)
+				return
+			case v, ok := <-in:
+				timer.Stop()
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case timeoutStream <- v:
+				}
+			}
+		}
+	})
+	return timeoutStream
+}
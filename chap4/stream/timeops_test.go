@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+func expectNext(t *testing.T, s Stream[int], expected int) {
+	t.Helper()
+	if v, ok := <-s; !ok || v != expected {
+		t.Fatalf("expected %v, but received %v (open: %v)", expected, v, ok)
+	}
+}
+
+func expectClosed(t *testing.T, s Stream[int]) {
+	t.Helper()
+	if v, ok := <-s; ok {
+		t.Fatalf("expected the stream to be closed, but received %v", v)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Time{})
+
+	in := make(chan int)
+	debounced := Debounce(ctx, in, 10*time.Millisecond, clk)
+
+	in <- 1
+	clk.BlockUntil(1)
+	clk.Advance(5 * time.Millisecond)
+	in <- 2
+	clk.BlockUntilArmed(clk.Now().Add(10 * time.Millisecond))
+	clk.Advance(5 * time.Millisecond) // 1 would have been emitted now
+	clk.Advance(5 * time.Millisecond)
+	expectNext(t, debounced, 2)
+
+	in <- 3
+	close(in)
+	expectNext(t, debounced, 3)
+	expectClosed(t, debounced)
+	if n := clk.Waiters(); n != 0 {
+		t.Errorf("expected every timer to be stopped, but %v are armed", n)
+	}
+}
+
+func TestThrottleFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Time{})
+
+	in := make(chan int)
+	throttled := ThrottleFirst(ctx, in, 10*time.Millisecond, clk)
+
+	in <- 1
+	expectNext(t, throttled, 1)
+	in <- 2
+	clk.Advance(9 * time.Millisecond)
+	in <- 3
+	clk.Advance(time.Millisecond)
+	in <- 4
+	expectNext(t, throttled, 4)
+	in <- 5
+	close(in)
+	expectClosed(t, throttled)
+}
+
+func TestSample(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Time{})
+
+	in := make(chan int)
+	sampled := Sample(ctx, in, 10*time.Millisecond, clk)
+	clk.BlockUntil(1)
+
+	in <- 1
+	in <- 2
+	clk.Advance(10 * time.Millisecond)
+	expectNext(t, sampled, 2)
+
+	clk.Advance(10 * time.Millisecond) // nothing new to sample
+	in <- 3
+	clk.Advance(10 * time.Millisecond)
+	expectNext(t, sampled, 3)
+
+	close(in)
+	expectClosed(t, sampled)
+}
+
+func TestTimeout(t *testing.T) {
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+	clk := clock.NewFake(time.Time{})
+
+	in := make(chan int)
+	timed := Timeout(ctx, in, 10*time.Millisecond, clk)
+	clk.BlockUntil(1)
+
+	clk.Advance(5 * time.Millisecond)
+	in <- 1
+	expectNext(t, timed, 1)
+	clk.BlockUntilArmed(clk.Now().Add(10 * time.Millisecond))
+	clk.Advance(5 * time.Millisecond) // the wait for 1 would have timed out now
+	clk.Advance(5 * time.Millisecond)
+	expectClosed(t, timed)
+
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrTimeout) {
+		t.Errorf("expected cause %v, but received %v", ErrTimeout, cause)
+	}
+}
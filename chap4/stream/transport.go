@@ -0,0 +1,281 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// TransportWindow is how many elements RecvStream lets a sender have in
+// flight before it has to wait for the receiver to catch up.
+const TransportWindow = 16
+
+const maxFrameSize = 16 << 20
+
+type frameType byte
+
+const (
+	frameData frameType = iota + 1
+	frameEnd
+	frameError
+	frameCredit
+)
+
+// Codec turns stream elements into frame payloads and back.
+type Codec[T any] interface {
+	Encode(T) ([]byte, error)
+	Decode([]byte) (T, error)
+}
+
+// GobCodec encodes each element as a standalone gob.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(&v)
+	return b.Bytes(), err
+}
+
+func (GobCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	return v, err
+}
+
+// JSONCodec encodes each element as JSON.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(b []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
+
+// RawCodec sends byte slices as they are.
+type RawCodec struct{}
+
+func (RawCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+func (RawCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+// RemoteError is the cancellation cause of a RecvStream pipeline whose
+// sender failed.
+type RemoteError struct {
+	Message string
+}
+
+func (err *RemoteError) Error() string {
+	return "remote stream: " + err.Message
+}
+
+// writeFrame writes a frame: a type byte, a big-endian uint32 payload
+// length and the payload.
+func writeFrame(w io.Writer, typ frameType, payload []byte) error {
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = byte(typ)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func readFrame(r io.Reader) (frameType, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds limit", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return frameType(header[0]), payload, nil
+}
+
+// unblockOnDone passes the current time to setDeadline once ctx is done, so
+// that pending reads or writes on a conn fail. The returned func stops it
+// and waits for a deadline it already set, so the caller can set its own
+// afterwards.
+func unblockOnDone(ctx context.Context, setDeadline func(time.Time) error) func() {
+	unblocked := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(unblocked)
+		setDeadline(time.Now())
+	})
+	return sync.OnceFunc(func() {
+		if !stop() {
+			<-unblocked
+		}
+	})
+}
+
+// SendStream writes the elements of in to conn until in closes, then sends
+// an end-of-stream frame. It never has more elements in flight than the
+// receiver has granted. If ctx is canceled or an element cannot be encoded
+// or is larger than a frame allows, the receiver is sent an error frame and
+// the error is returned. A canceled ctx also interrupts a write to a
+// receiver that stopped reading, in which case no error frame can be sent
+// after the partial one. The caller
+// still owns conn and should close it once SendStream returns; its
+// deadlines are cleared, but credits the receiver sends late are left
+// unread on it.
+func SendStream[T any](ctx context.Context, conn net.Conn, in Stream[T], codec Codec[T]) error {
+	credits := make(chan int)
+	readErr := make(chan error, 1)
+	stopped := make(chan struct{})
+	reading := make(chan struct{})
+	stop := unblockOnDone(ctx, conn.SetWriteDeadline)
+	defer func() {
+		stop()
+		close(stopped)
+		conn.SetReadDeadline(time.Now())
+		<-reading
+		conn.SetDeadline(time.Time{})
+	}()
+	Go(ctx, "SendStream", func(g *Goroutine) {
+		defer close(reading)
+		for {
+			typ, payload, err := readFrame(conn)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if typ != frameCredit || len(payload) != 4 {
+				readErr <- fmt.Errorf("unexpected frame %d from receiver", typ)
+				return
+			}
+			select {
+			case credits <- int(binary.BigEndian.Uint32(payload)):
+			case <-stopped:
+				return
+			}
+		}
+	})
+
+	fail := func(err error) error {
+		stop()
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		writeFrame(conn, frameError, []byte(err.Error()))
+		return err
+	}
+
+	available := 0
+	for {
+		v, ok := recv(ctx, in)
+		if !ok {
+			if ctx.Err() != nil {
+				return fail(context.Cause(ctx))
+			}
+			return writeFrame(conn, frameEnd, nil)
+		}
+
+		for available == 0 {
+			select {
+			case <-ctx.Done():
+				return fail(context.Cause(ctx))
+			case err := <-readErr:
+				return err
+			case n := <-credits:
+				available += n
+			}
+		}
+
+		payload, err := codec.Encode(v)
+		if err != nil {
+			return fail(err)
+		}
+		if len(payload) > maxFrameSize {
+			return fail(fmt.Errorf("element of %d bytes exceeds frame limit", len(payload)))
+		}
+		if err := writeFrame(conn, frameData, payload); err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+		available--
+	}
+}
+
+// RecvStream reads a stream sent by SendStream from conn. The stream closes
+// after the end-of-stream frame. If the sender fails, or conn or codec
+// does, the pipeline stops with that error as its cause; a failure on the
+// sender's side arrives as a *RemoteError. Since a stream cut off by the
+// sender could not be told from a complete one otherwise, RecvStream
+// panics unless ctx comes from NewContext or DoneContext.
+func RecvStream[T any](ctx context.Context, conn net.Conn, codec Codec[T]) Stream[T] {
+	mustFail(ctx, "RecvStream")
+	recvStream := make(chan T)
+	Go(ctx, "RecvStream", func(g *Goroutine) {
+		defer close(recvStream)
+		stop := unblockOnDone(ctx, conn.SetDeadline)
+		defer stop()
+
+		grant := func(n int) error {
+			var payload [4]byte
+			binary.BigEndian.PutUint32(payload[:], uint32(n))
+			return writeFrame(conn, frameCredit, payload[:])
+		}
+		if err := grant(TransportWindow); err != nil {
+			Fail(ctx, err)
+			return
+		}
+
+		consumed := 0
+		for {
+			typ, payload, err := readFrame(conn)
+			if err != nil {
+				if ctx.Err() == nil {
+					Fail(ctx, err)
+				}
+				return
+			}
+
+			switch typ {
+			case frameEnd:
+				return
+			case frameError:
+				Fail(ctx, &RemoteError{Message: string(payload)})
+				return
+			case frameData:
+			default:
+				Fail(ctx, fmt.Errorf("unexpected frame %d from sender", typ))
+				return
+			}
+
+			v, err := codec.Decode(payload)
+			if err != nil {
+				Fail(ctx, err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case recvStream <- v:
+			}
+
+			// Hand credits back in batches of half the window.
+			if consumed++; consumed >= TransportWindow/2 {
+				if err := grant(consumed); err != nil && !errors.Is(err, net.ErrClosed) {
+					Fail(ctx, err)
+					return
+				}
+				consumed = 0
+			}
+		}
+	})
+	return recvStream
+}
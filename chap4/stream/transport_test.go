@@ -0,0 +1,227 @@
+package stream
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type reading struct {
+	Sensor string
+	Value  float64
+}
+
+// listenPair connects a client and a server conn over network.
+func listenPair(t *testing.T, network, address string) (net.Conn, net.Conn) {
+	t.Helper()
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("cannot listen on %v: %v", network, err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			t.Errorf("cannot accept: %v", err)
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial(network, l.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot dial: %v", err)
+	}
+	server := <-accepted
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func sendAndReceive[T any](t *testing.T, sender, receiver net.Conn, codec Codec[T], values ...T) []T {
+	t.Helper()
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	sent := make(chan error, 1)
+	go func() { sent <- SendStream(ctx, sender, Generate(ctx, values...), codec) }()
+	received := collect(RecvStream(ctx, receiver, codec))
+	if err := <-sent; err != nil {
+		t.Errorf("cannot send: %v", err)
+	}
+	return received
+}
+
+func TestTransportCodecs(t *testing.T) {
+	readings := make([]reading, 3*TransportWindow)
+	for i := range readings {
+		readings[i] = reading{Sensor: "s", Value: float64(i)}
+	}
+
+	t.Run("gob over tcp", func(t *testing.T) {
+		client, server := listenPair(t, "tcp", "127.0.0.1:0")
+		if actual := sendAndReceive(t, client, server, GobCodec[reading]{}, readings...); !reflect.DeepEqual(actual, readings) {
+			t.Errorf("expected %v, but received %v", readings, actual)
+		}
+	})
+	t.Run("json over unix", func(t *testing.T) {
+		client, server := listenPair(t, "unix", filepath.Join(t.TempDir(), "stream.sock"))
+		if actual := sendAndReceive(t, client, server, JSONCodec[reading]{}, readings...); !reflect.DeepEqual(actual, readings) {
+			t.Errorf("expected %v, but received %v", readings, actual)
+		}
+	})
+	t.Run("raw over pipe", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+		expected := [][]byte{[]byte("I"), []byte("am."), {}}
+		if actual := sendAndReceive(t, client, server, RawCodec{}, expected...); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected %q, but received %q", expected, actual)
+		}
+	})
+}
+
+func TestTransportBackpressure(t *testing.T) {
+	client, server := listenPair(t, "tcp", "127.0.0.1:0")
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	var pulled atomic.Int64
+	source := RepeatFn(ctx, func() int { return int(pulled.Add(1)) })
+	go SendStream(ctx, client, source, GobCodec[int]{})
+	received := RecvStream(ctx, server, GobCodec[int]{})
+
+	// Nobody reads received, so the sender must stop once the window is
+	// used up: one element waiting in RecvStream, TransportWindow in
+	// flight and one pulled while waiting for credit.
+	time.Sleep(50 * time.Millisecond)
+	if n := pulled.Load(); n > TransportWindow+2 {
+		t.Errorf("expected the sender to stop after about %v elements, but it pulled %v", TransportWindow, n)
+	}
+
+	for i := 1; i <= 3*TransportWindow; i++ {
+		if v := <-received; v != i {
+			t.Fatalf("expected %v, but received %v", i, v)
+		}
+	}
+}
+
+func TestTransportRemoteError(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	errUpstream := errors.New("upstream failure")
+	sendCtx, sendCancel := context.WithCancelCause(context.Background())
+	recvCtx, recvCancel := NewContext(context.Background())
+	defer recvCancel(nil)
+
+	in := make(chan int)
+	sent := make(chan error, 1)
+	go func() { sent <- SendStream(sendCtx, client, in, GobCodec[int]{}) }()
+	received := RecvStream(recvCtx, server, GobCodec[int]{})
+
+	in <- 1
+	if v := <-received; v != 1 {
+		t.Errorf("expected 1, but received %v", v)
+	}
+	sendCancel(errUpstream)
+	collect(received)
+
+	if err := <-sent; !errors.Is(err, errUpstream) {
+		t.Errorf("expected SendStream to return %v, but received %v", errUpstream, err)
+	}
+	var remote *RemoteError
+	if cause := context.Cause(recvCtx); !errors.As(cause, &remote) || remote.Message != errUpstream.Error() {
+		t.Errorf("expected a RemoteError for %v, but received %v", errUpstream, cause)
+	}
+}
+
+func TestTransportSendCanceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The receiver grants credit and then stops reading, so the first data
+	// frame blocks on the pipe.
+	go func() {
+		var credit [4]byte
+		binary.BigEndian.PutUint32(credit[:], TransportWindow)
+		writeFrame(server, frameCredit, credit[:])
+	}()
+	sent := make(chan error, 1)
+	go func() { sent <- SendStream(ctx, client, Repeat(ctx, 1), GobCodec[int]{}) }()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-sent:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v, but received %v", context.Canceled, err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected canceling ctx to interrupt the blocked write")
+	}
+}
+
+func TestTransportFrameLimit(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := NewContext(context.Background())
+	defer cancel(nil)
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- SendStream(ctx, client, Generate(ctx, make([]byte, maxFrameSize+1)), RawCodec{})
+	}()
+	collect(RecvStream(ctx, server, RawCodec{}))
+
+	if err := <-sent; err == nil {
+		t.Error("expected SendStream to refuse an oversized element")
+	}
+	var remote *RemoteError
+	if cause := context.Cause(ctx); !errors.As(cause, &remote) {
+		t.Errorf("expected a RemoteError, but received %v", cause)
+	}
+}
+
+func TestRecvStreamWithoutPipeline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected RecvStream to panic without a pipeline context")
+		}
+	}()
+	RecvStream(ctx, server, GobCodec[int]{})
+}
+
+func TestTransportConnReusable(t *testing.T) {
+	client, server := listenPair(t, "tcp", "127.0.0.1:0")
+	sendAndReceive(t, client, server, GobCodec[reading]{}, reading{Sensor: "s"})
+
+	// SendStream interrupts its credit reader with a deadline, which must
+	// not outlive it.
+	if _, err := server.Write([]byte("ok")); err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(client, buf); err != nil || string(buf) != "ok" {
+		t.Errorf("expected to read %q after SendStream, but received %q (%v)", "ok", buf, err)
+	}
+}
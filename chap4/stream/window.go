@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+// flushWindow sends window on out. Once ctx is canceled it makes one last
+// non-blocking attempt, so a consumer that is still reading receives the
+// partial window without the stage blocking on one that has gone away. It
+// reports whether ctx is still live.
+func flushWindow[T any](ctx context.Context, out chan<- []T, window []T) bool {
+	if len(window) == 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case out <- window:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		select {
+		case out <- window:
+		default:
+		}
+		return false
+	}
+}
+
+// WindowCount groups in into consecutive windows of size elements.
+// WindowCount panics unless size is positive.
+func WindowCount[T any](ctx context.Context, in Stream[T], size int) Stream[[]T] {
+	if size < 1 {
+		panic(fmt.Sprintf("stream: WindowCount size must be positive, got %d", size))
+	}
+	windowStream := make(chan []T, 1)
+	Go(ctx, "WindowCount", func(g *Goroutine) {
+		defer close(windowStream)
+		var window []T
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				flushWindow(ctx, windowStream, window)
+				return
+			}
+			window = append(window, v)
+			if len(window) >= size {
+				if !flushWindow(ctx, windowStream, window) {
+					return
+				}
+				window = nil
+			}
+		}
+	})
+	return windowStream
+}
+
+// WindowTime groups in into consecutive windows spanning d. Windows in
+// which nothing arrived are skipped. WindowTime panics unless d is
+// positive.
+func WindowTime[T any](ctx context.Context, in Stream[T], d time.Duration, clk clock.Clock) Stream[[]T] {
+	if d <= 0 {
+		panic(fmt.Sprintf("stream: WindowTime duration must be positive, got %v", d))
+	}
+	windowStream := make(chan []T, 1)
+	Go(ctx, "WindowTime", func(g *Goroutine) {
+		defer close(windowStream)
+		ticker := clk.NewTicker(d)
+		defer ticker.Stop()
+
+		var window []T
+		for {
+			select {
+			case <-ctx.Done():
+				flushWindow(ctx, windowStream, window)
+				return
+			case v, ok := <-in:
+				if !ok {
+					flushWindow(ctx, windowStream, window)
+					return
+				}
+				window = append(window, v)
+			case <-ticker.C():
+				if !flushWindow(ctx, windowStream, window) {
+					return
+				}
+				window = nil
+			}
+		}
+	})
+	return windowStream
+}
+
+// WindowSliding emits the last size elements every step elements. If
+// elements arrived since the last window when in closes, the last size of
+// them are emitted in a final window, which is shorter than size only if
+// fewer than size elements arrived in all. WindowSliding panics unless
+// size and step are positive.
+func WindowSliding[T any](ctx context.Context, in Stream[T], size, step int) Stream[[]T] {
+	if size < 1 || step < 1 {
+		panic(fmt.Sprintf("stream: WindowSliding size and step must be positive, got %d and %d", size, step))
+	}
+	windowStream := make(chan []T, 1)
+	Go(ctx, "WindowSliding", func(g *Goroutine) {
+		defer close(windowStream)
+		var window []T
+		fresh := 0
+		for {
+			v, ok := recv(ctx, in)
+			if !ok {
+				if fresh > 0 {
+					flushWindow(ctx, windowStream, window)
+				}
+				return
+			}
+			window = append(window, v)
+			fresh++
+			if len(window) > size {
+				window = window[len(window)-size:]
+			}
+			if len(window) == size && fresh >= step {
+				emitted := append([]T(nil), window...)
+				if !flushWindow(ctx, windowStream, emitted) {
+					return
+				}
+				fresh = 0
+			}
+		}
+	})
+	return windowStream
+}
+
+// WindowSession groups elements that arrive within gap of each other. A
+// window is emitted once in has been idle for gap. WindowSession panics
+// unless gap is positive.
+func WindowSession[T any](ctx context.Context, in Stream[T], gap time.Duration, clk clock.Clock) Stream[[]T] {
+	if gap <= 0 {
+		panic(fmt.Sprintf("stream: WindowSession gap must be positive, got %v", gap))
+	}
+	windowStream := make(chan []T, 1)
+	Go(ctx, "WindowSession", func(g *Goroutine) {
+		defer close(windowStream)
+		var window []T
+		var timer clock.Timer
+		var idle <-chan time.Time
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				flushWindow(ctx, windowStream, window)
+				return
+			case v, ok := <-in:
+				if !ok {
+					flushWindow(ctx, windowStream, window)
+					return
+				}
+				window = append(window, v)
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = clk.NewTimer(gap)
+				idle = timer.C()
+			case <-idle:
+				if !flushWindow(ctx, windowStream, window) {
+					return
+				}
+				window = nil
+				idle = nil
+			}
+		}
+	})
+	return windowStream
+}
@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
+)
+
+func TestCountWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   func(context.Context, Stream[int]) Stream[[]int]
+		expected [][]int
+	}{
+		{
+			name: "tumbling",
+			window: func(ctx context.Context, in Stream[int]) Stream[[]int] {
+				return WindowCount(ctx, in, 3)
+			},
+			expected: [][]int{{1, 2, 3}, {4, 5, 6}, {7}},
+		},
+		{
+			name: "sliding",
+			window: func(ctx context.Context, in Stream[int]) Stream[[]int] {
+				return WindowSliding(ctx, in, 3, 2)
+			},
+			expected: [][]int{{1, 2, 3}, {3, 4, 5}, {5, 6, 7}},
+		},
+		{
+			name: "sliding with tail",
+			window: func(ctx context.Context, in Stream[int]) Stream[[]int] {
+				return WindowSliding(ctx, in, 3, 4)
+			},
+			expected: [][]int{{2, 3, 4}, {5, 6, 7}},
+		},
+		{
+			name: "sliding with partial tail",
+			window: func(ctx context.Context, in Stream[int]) Stream[[]int] {
+				return WindowSliding(ctx, in, 10, 3)
+			},
+			expected: [][]int{{1, 2, 3, 4, 5, 6, 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			actual := collect(tt.window(ctx, Generate(ctx, 1, 2, 3, 4, 5, 6, 7)))
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWindowSlidingArguments(t *testing.T) {
+	for _, args := range [][2]int{{0, 1}, {3, 0}, {-1, -1}} {
+		t.Run(fmt.Sprint(args), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected WindowSliding(%v, %v) to panic", args[0], args[1])
+				}
+			}()
+			WindowSliding(ctx, Generate(ctx, 1), args[0], args[1])
+		})
+	}
+}
+
+func TestWindowArguments(t *testing.T) {
+	windows := map[string]func(context.Context, Stream[int]){
+		"count":   func(ctx context.Context, in Stream[int]) { WindowCount(ctx, in, 0) },
+		"time":    func(ctx context.Context, in Stream[int]) { WindowTime(ctx, in, 0, clock.Real()) },
+		"session": func(ctx context.Context, in Stream[int]) { WindowSession(ctx, in, -time.Second, clock.Real()) },
+	}
+
+	for name, window := range windows {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected the %v window to panic", name)
+				}
+			}()
+			window(ctx, Generate(ctx, 1))
+		})
+	}
+}
+
+func expectWindow(t *testing.T, s Stream[[]int], expected []int) {
+	t.Helper()
+	if actual, ok := <-s; !ok || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, but received %v (open: %v)", expected, actual, ok)
+	}
+}
+
+func TestWindowTime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Time{})
+
+	in := make(chan int)
+	windows := WindowTime(ctx, in, 50*time.Millisecond, clk)
+
+	in <- 1
+	clk.Advance(25 * time.Millisecond)
+	in <- 2
+	clk.Advance(25 * time.Millisecond)
+	expectWindow(t, windows, []int{1, 2})
+
+	in <- 3
+	in <- 4
+	in <- 5
+	clk.Advance(50 * time.Millisecond)
+	expectWindow(t, windows, []int{3, 4, 5})
+
+	close(in)
+	if w, ok := <-windows; ok {
+		t.Errorf("expected the stream to be closed, but received %v", w)
+	}
+}
+
+func TestWindowSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Time{})
+	gap := 50 * time.Millisecond
+
+	in := make(chan int)
+	windows := WindowSession(ctx, in, gap, clk)
+
+	// 2 arrives before the session of 1 has been idle for gap.
+	in <- 1
+	clk.BlockUntilArmed(clk.Now().Add(gap))
+	clk.Advance(gap / 2)
+	in <- 2
+	clk.BlockUntilArmed(clk.Now().Add(gap))
+	clk.Advance(gap)
+	expectWindow(t, windows, []int{1, 2})
+
+	in <- 3
+	close(in)
+	expectWindow(t, windows, []int{3})
+	if w, ok := <-windows; ok {
+		t.Errorf("expected the stream to be closed, but received %v", w)
+	}
+	if n := clk.Waiters(); n != 0 {
+		t.Errorf("expected every timer to be stopped, but %v are armed", n)
+	}
+}
+
+func TestWindowFlushOnCancel(t *testing.T) {
+	windows := map[string]func(context.Context, Stream[int]) Stream[[]int]{
+		"count": func(ctx context.Context, in Stream[int]) Stream[[]int] {
+			return WindowCount(ctx, in, 10)
+		},
+		"time": func(ctx context.Context, in Stream[int]) Stream[[]int] {
+			return WindowTime(ctx, in, time.Hour, clock.Real())
+		},
+		"sliding": func(ctx context.Context, in Stream[int]) Stream[[]int] {
+			return WindowSliding(ctx, in, 10, 5)
+		},
+		"session": func(ctx context.Context, in Stream[int]) Stream[[]int] {
+			return WindowSession(ctx, in, time.Hour, clock.Real())
+		},
+	}
+
+	for name, window := range windows {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan int)
+			windowStream := window(ctx, in)
+			in <- 1
+			in <- 2
+			cancel()
+
+			expected := [][]int{{1, 2}}
+			if actual := collect(windowStream); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %v, but received %v", expected, actual)
+			}
+		})
+	}
+}
@@ -4,40 +4,36 @@ import (
 	"context"
 	"log"
 	"os"
-	"sort"
 	"sync"
 	"time"
 
+	"github.com/cipepser/go-concurrency/chap5/ratelimit"
 	"golang.org/x/time/rate"
 )
 
-func Per(eventCount int, duration time.Duration) rate.Limit {
-	return rate.Every(duration / time.Duration(eventCount))
-}
-
 type APIConnection struct {
 	networkLimit,
 	diskLimit,
-	apiLimit RateLimiter
+	apiLimit ratelimit.RateLimiter
 }
 
 func Open() *APIConnection {
 	return &APIConnection{
-		networkLimit: MultiLimiter(
-			rate.NewLimiter(Per(2, time.Second), 2),
-			rate.NewLimiter(Per(10, time.Minute), 10),
+		networkLimit: ratelimit.MultiLimiter(
+			rate.NewLimiter(ratelimit.Per(2, time.Second), 2),
+			rate.NewLimiter(ratelimit.Per(10, time.Minute), 10),
 		),
-		diskLimit: MultiLimiter(
+		diskLimit: ratelimit.MultiLimiter(
 			rate.NewLimiter(rate.Limit(1), 1),
 		),
-		apiLimit: MultiLimiter(
-			rate.NewLimiter(Per(3, time.Second), 3),
+		apiLimit: ratelimit.MultiLimiter(
+			rate.NewLimiter(ratelimit.Per(3, time.Second), 3),
 		),
 	}
 }
 
 func (a *APIConnection) ReadFile(ctx context.Context) error {
-	if err := MultiLimiter(a.apiLimit, a.diskLimit).Wait(ctx); err != nil {
+	if err := ratelimit.MultiLimiter(a.apiLimit, a.diskLimit).Wait(ctx); err != nil {
 		return err
 	}
 	// do something
@@ -45,45 +41,13 @@ func (a *APIConnection) ReadFile(ctx context.Context) error {
 }
 
 func (a *APIConnection) ResolveAddress(ctx context.Context) error {
-	if err := MultiLimiter(a.apiLimit, a.networkLimit).Wait(ctx); err != nil {
+	if err := ratelimit.MultiLimiter(a.apiLimit, a.networkLimit).Wait(ctx); err != nil {
 		return err
 	}
 	// do something
 	return nil
 }
 
-type RateLimiter interface {
-	Wait(context.Context) error
-	Limit() rate.Limit
-}
-
-type multiLimiter struct {
-	limiters []RateLimiter
-}
-
-func (l *multiLimiter) Wait(ctx context.Context) error {
-	for _, l := range l.limiters {
-		if err := l.Wait(ctx); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (l *multiLimiter) Limit() rate.Limit {
-	return l.limiters[0].Limit()
-}
-
-func MultiLimiter(limiters ...RateLimiter) *multiLimiter {
-	sort.Slice(limiters, func(i, j int) bool {
-		return limiters[i].Limit() < limiters[j].Limit()
-	})
-
-	return &multiLimiter{
-		limiters: limiters,
-	}
-}
-
 func main() {
 	defer log.Printf("Done.")
 	log.SetOutput(os.Stdout)
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math/rand"
 	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/clock"
 )
 
 func DoWork() {
@@ -193,13 +195,19 @@ func heartbeatForEachWork() {
 }
 
 func DoWorkMock(done <-chan interface{}, nums ...int) (<-chan interface{}, <-chan int) {
+	return DoWorkMockWithClock(done, clock.Real(), nums...)
+}
+
+// DoWorkMockWithClock is DoWorkMock with its simulated work timed by clk,
+// so tests can skip the two seconds with a fake clock.
+func DoWorkMockWithClock(done <-chan interface{}, clk clock.Clock, nums ...int) (<-chan interface{}, <-chan int) {
 	heartbeat := make(chan interface{}, 1)
 	intStream := make(chan int)
 	go func() {
 		defer close(heartbeat)
 		defer close(intStream)
 
-		time.Sleep(2 * time.Second) // simulate to do something
+		clk.Sleep(2 * time.Second) // simulate to do something
 
 		for _, n := range nums {
 			select {
@@ -222,6 +230,17 @@ func DoWorkMockWithInterval(
 	done <-chan interface{},
 	pulseIntreval time.Duration,
 	nums ...int,
+) (<-chan interface{}, <-chan int) {
+	return DoWorkMockWithIntervalClock(done, clock.Real(), pulseIntreval, nums...)
+}
+
+// DoWorkMockWithIntervalClock is DoWorkMockWithInterval with its simulated
+// work and pulses timed by clk.
+func DoWorkMockWithIntervalClock(
+	done <-chan interface{},
+	clk clock.Clock,
+	pulseIntreval time.Duration,
+	nums ...int,
 ) (<-chan interface{}, <-chan int) {
 	heartbeat := make(chan interface{}, 1)
 	intStream := make(chan int)
@@ -229,9 +248,10 @@ func DoWorkMockWithInterval(
 		defer close(heartbeat)
 		defer close(intStream)
 
-		time.Sleep(2 * time.Second) // simulate to do something
+		clk.Sleep(2 * time.Second) // simulate to do something
 
-		pulse := time.Tick(pulseIntreval)
+		pulse := clk.NewTicker(pulseIntreval)
+		defer pulse.Stop()
 
 	numLoop:
 		for _, n := range nums {
@@ -239,7 +259,7 @@ func DoWorkMockWithInterval(
 				select {
 				case <-done:
 					return
-				case <-pulse:
+				case <-pulse.C():
 					select {
 					case heartbeat <- struct{}{}:
 					default:
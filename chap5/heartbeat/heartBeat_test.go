@@ -1,18 +1,28 @@
 package heartbeat
 
 import (
+	"context"
 	"testing"
 	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/streamtest"
 )
 
 func TestDoWorkMock(t *testing.T) {
+	streamtest.NoLeaks(t)
 	done := make(chan interface{})
 	defer close(done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	clk := streamtest.NewClock()
+	sched := streamtest.NewScheduler(streamtest.Seed(t))
 	intSlice := []int{0, 1, 2, 3, 5}
-	heartbeat, results := DoWorkMock(done, intSlice...)
+	heartbeat, results := DoWorkMockWithClock(done, clk, intSlice...)
+	results = streamtest.Perturb(ctx, sched, results)
 
-	<-heartbeat
+	// The worker sleeps 2 seconds of virtual time before its first pulse.
+	streamtest.EventuallyEmits(t, clk, heartbeat, interface{}(struct{}{}), 2*time.Second)
 
 	for i, expected := range intSlice {
 		select {
@@ -33,33 +43,42 @@ func TestDoWorkMock(t *testing.T) {
 	// good case: use `<-heartbeat`
 	//❯ go test ./heartbeat
 	//ok  	github.com/cipepser/go-concurrency/chap5/heartbeat	2.010s
+
+	// with a fake clock the 2 seconds are virtual
+	//❯ go test ./heartbeat
+	//ok  	github.com/cipepser/go-concurrency/chap5/heartbeat	0.034s
 }
 
 func TestDoWorkMockWithInterval(t *testing.T) {
+	streamtest.NoLeaks(t)
 	done := make(chan interface{})
 	defer close(done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
+	clk := streamtest.NewClock()
+	sched := streamtest.NewScheduler(streamtest.Seed(t))
 	intSlice := []int{0, 1, 2, 3, 5}
 	const timeout = 2 * time.Second
-	heartbeat, results := DoWorkMockWithInterval(done, timeout/2, intSlice...)
+	heartbeat, results := DoWorkMockWithIntervalClock(done, clk, timeout/2, intSlice...)
+	results = streamtest.Perturb(ctx, sched, results)
 
-	<-heartbeat
+	streamtest.EventuallyEmits(t, clk, heartbeat, interface{}(struct{}{}), 2*time.Second+timeout)
 
-	i := 0
-	for {
+	for i, expected := range intSlice {
 		select {
 		case r, ok := <-results:
 			if !ok {
-				return
+				t.Fatalf("index %v: expected %v, but results closed", i, expected)
 			}
-			if expected := intSlice[i]; r != expected {
+			if r != expected {
 				t.Errorf("index %v: expected %v, but received %v", i, expected, r)
 			}
-			i++
-		case <-heartbeat:
-		case <-time.After(timeout):
+		case <-time.After(streamtest.Patience):
 			t.Fatal("test timed out")
 		}
 	}
-
+	if r, ok := <-results; ok {
+		t.Errorf("expected results to close, but received %v", r)
+	}
 }
@@ -0,0 +1,133 @@
+package supervisor
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrHeartbeatMissed is the error of a ward that went a whole timeout
+	// without a heartbeat.
+	ErrHeartbeatMissed = errors.New("supervisor: heartbeat missed")
+	// ErrWardExited is the error of a ward that closed its heartbeat.
+	ErrWardExited = errors.New("supervisor: ward exited")
+	// ErrAbandoned is the error of a ward that was told to stop but did
+	// not close its heartbeat within its Shutdown.
+	ErrAbandoned = errors.New("supervisor: ward abandoned")
+)
+
+// EventKind says what happened to a ward or its steward.
+type EventKind int
+
+const (
+	// WardStarted is published every time a ward is started.
+	WardStarted EventKind = iota
+	// HeartbeatMissed is published when a ward's timeout passes without a
+	// heartbeat.
+	HeartbeatMissed
+	// WardRestarting is published when a ward is stopped to be restarted,
+	// either because it failed or because the strategy restarts it along
+	// with a sibling.
+	WardRestarting
+	// WardExited is published when a ward closes its heartbeat. Its Err is
+	// nil if the ward had been told to stop.
+	WardExited
+	// StewardStopped is published when the supervisor stops, because its
+	// done channel was closed, because Stop was called or because it gave
+	// up.
+	StewardStopped
+	// WardAbandoned is published when a ward told to stop does not exit
+	// within its Shutdown.
+	WardAbandoned
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case WardStarted:
+		return "WardStarted"
+	case HeartbeatMissed:
+		return "HeartbeatMissed"
+	case WardRestarting:
+		return "WardRestarting"
+	case WardExited:
+		return "WardExited"
+	case StewardStopped:
+		return "StewardStopped"
+	case WardAbandoned:
+		return "WardAbandoned"
+	}
+	return "EventKind(?)"
+}
+
+// Event is a lifecycle event published by a Supervisor or Steward.
+type Event struct {
+	Kind       EventKind
+	Supervisor string
+	// Ward is empty for StewardStopped.
+	Ward string
+	// Attempt counts the starts of the ward, starting at 1.
+	Attempt int
+	Time    time.Time
+	// Started is when the current attempt of the ward started and
+	// LastHeartbeat when it last pulsed; it is zero if it never did.
+	Started       time.Time
+	LastHeartbeat time.Time
+	// NextStart is when a restarting ward will be started again.
+	NextStart time.Time
+	// Err is the last error of the ward: ErrHeartbeatMissed,
+	// ErrWardExited, or the error of the sibling it was restarted with. It
+	// stays set on the WardStarted of the restart. For StewardStopped it
+	// is the error the supervisor gave up with, or the error of abandoning
+	// wards as it stopped.
+	Err error
+}
+
+// Subscribe returns a channel that receives the supervisor's events, and a
+// function that unsubscribes and closes it. The supervisor never waits for
+// a subscriber: events that do not fit in buffer are dropped.
+func (s *Supervisor) Subscribe(buffer int) (<-chan Event, func()) {
+	events := make(chan Event, buffer)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, events)
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.subscribers {
+			if sub == events {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				close(events)
+				return
+			}
+		}
+	}
+	return events, unsubscribe
+}
+
+func (s *Supervisor) publish(e Event) {
+	e.Supervisor = s.name
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subscribers {
+		select {
+		case sub <- e:
+		default:
+		}
+	}
+}
+
+// event describes c for an event of kind.
+func (c *child) event(kind EventKind) Event {
+	return Event{
+		Kind:          kind,
+		Ward:          c.spec.Name,
+		Attempt:       c.attempt,
+		Started:       c.started,
+		LastHeartbeat: c.lastHeartbeat,
+		Err:           c.err,
+	}
+}
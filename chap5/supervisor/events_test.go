@@ -0,0 +1,140 @@
+package supervisor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/streamtest"
+)
+
+// receiveEvents waits for n events.
+func receiveEvents(t *testing.T, events <-chan Event, n int) []Event {
+	t.Helper()
+	var received []Event
+	for len(received) < n {
+		select {
+		case e := <-events:
+			received = append(received, e)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %v events, but received %v", n, received)
+		}
+	}
+	return received
+}
+
+type eventSummary struct {
+	Kind    EventKind
+	Ward    string
+	Attempt int
+	Err     error
+}
+
+func summarize(events []Event) []eventSummary {
+	summaries := make([]eventSummary, len(events))
+	for i, e := range events {
+		summaries[i] = eventSummary{Kind: e.Kind, Ward: e.Ward, Attempt: e.Attempt, Err: e.Err}
+	}
+	return summaries
+}
+
+func TestStewardEvents(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+
+	starts := make(chan string, 10)
+	steward := NewSteward(time.Second, newWard("worker", 1, starts)).WithWardName("worker")
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	received := receiveEvents(t, events, 4)
+	close(done)
+	received = append(received, receiveEvents(t, events, 2)...)
+
+	expected := []eventSummary{
+		{Kind: WardStarted, Ward: "worker", Attempt: 1},
+		{Kind: WardExited, Ward: "worker", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardRestarting, Ward: "worker", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardStarted, Ward: "worker", Attempt: 2, Err: ErrWardExited},
+		{Kind: WardExited, Ward: "worker", Attempt: 2},
+		{Kind: StewardStopped},
+	}
+	if actual := summarize(received); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	for _, e := range received {
+		if e.Supervisor != "steward" || e.Time.IsZero() {
+			t.Errorf("expected a timestamped event from the steward, but received %+v", e)
+		}
+	}
+	if restarting := received[2]; restarting.NextStart.Before(restarting.Started) {
+		t.Errorf("expected the restart after the attempt started, but received %+v", restarting)
+	}
+}
+
+func TestStewardEventsHeartbeatMissed(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	steward := NewSteward(10*time.Millisecond, newIrresponsibleWard("ward", starts)).WithIntensity(1, time.Minute)
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	expected := []eventSummary{
+		{Kind: WardStarted, Ward: "ward", Attempt: 1},
+		{Kind: HeartbeatMissed, Ward: "ward", Attempt: 1, Err: ErrHeartbeatMissed},
+		{Kind: WardRestarting, Ward: "ward", Attempt: 1, Err: ErrHeartbeatMissed},
+		{Kind: WardExited, Ward: "ward", Attempt: 1},
+		{Kind: WardStarted, Ward: "ward", Attempt: 2, Err: ErrHeartbeatMissed},
+		{Kind: HeartbeatMissed, Ward: "ward", Attempt: 2, Err: ErrHeartbeatMissed},
+		{Kind: WardExited, Ward: "ward", Attempt: 2},
+	}
+	received := receiveEvents(t, events, len(expected)+1)
+	if actual := summarize(received[:len(expected)]); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if stopped := received[len(expected)]; stopped.Kind != StewardStopped || !errors.Is(stopped.Err, ErrTooManyRestarts) {
+		t.Errorf("expected the steward to stop with %v, but received %+v", ErrTooManyRestarts, stopped)
+	}
+	if missed := received[1]; !missed.LastHeartbeat.IsZero() {
+		t.Errorf("expected no heartbeat, but the last one was at %v", missed.LastHeartbeat)
+	}
+}
+
+func TestSupervisorEventsSiblings(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	sup := New(OneForAll,
+		ChildSpec{Name: "a", Start: newWard("a", 0, starts), Timeout: time.Second},
+		ChildSpec{Name: "b", Start: newWard("b", 1, starts), Timeout: time.Second},
+	).WithName("tree")
+	events, unsubscribe := sup.Subscribe(10)
+	defer unsubscribe()
+	sup.Start(done, time.Hour)
+
+	expected := []eventSummary{
+		{Kind: WardStarted, Ward: "a", Attempt: 1},
+		{Kind: WardStarted, Ward: "b", Attempt: 1},
+		{Kind: WardExited, Ward: "b", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardRestarting, Ward: "b", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardRestarting, Ward: "a", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardExited, Ward: "a", Attempt: 1},
+		{Kind: WardStarted, Ward: "a", Attempt: 2, Err: ErrWardExited},
+		{Kind: WardStarted, Ward: "b", Attempt: 2, Err: ErrWardExited},
+	}
+	received := receiveEvents(t, events, len(expected))
+	if actual := summarize(received); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if received[0].Supervisor != "tree" {
+		t.Errorf("expected events from tree, but received %v", received[0].Supervisor)
+	}
+}
@@ -0,0 +1,228 @@
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// The environment variables a process ward finds its heartbeat in.
+const (
+	// EnvHeartbeatFD names the file descriptor of the heartbeat pipe.
+	EnvHeartbeatFD = "WARD_HEARTBEAT_FD"
+	// EnvHeartbeatSocket names the Unix socket to dial for heartbeats.
+	EnvHeartbeatSocket = "WARD_HEARTBEAT_SOCKET"
+	// EnvPulseInterval is how often the process is asked to pulse, as a
+	// time.Duration string.
+	EnvPulseInterval = "WARD_PULSE_INTERVAL"
+)
+
+// HeartbeatTransport is how a process ward sends its heartbeats.
+type HeartbeatTransport int
+
+const (
+	// HeartbeatPipe hands the process the write end of a pipe as file
+	// descriptor 3.
+	HeartbeatPipe HeartbeatTransport = iota
+	// HeartbeatSocket has the process dial a Unix socket.
+	HeartbeatSocket
+)
+
+// ProcessSpec describes an OS process to run as a ward. Every write the
+// process makes to its heartbeat counts as one pulse; ProcessHeartbeat
+// opens the heartbeat from inside the process.
+type ProcessSpec struct {
+	Path string
+	Args []string
+	// Env is the environment of the process, os.Environ() if nil. The
+	// heartbeat variables are added to it.
+	Env       []string
+	Dir       string
+	Heartbeat HeartbeatTransport
+	// KillTimeout is how long the process gets to exit after SIGTERM
+	// before it is sent SIGKILL. DefaultKillTimeout is used if it is zero.
+	// The ward may take up to twice KillTimeout to exit, so the Shutdown
+	// of its ChildSpec must be longer than that or the process is
+	// abandoned before it is killed.
+	KillTimeout time.Duration
+	// Stdout and Stderr receive the output of the process, which is
+	// discarded if they are nil. A RotatingFile keeps it on disk.
+	Stdout, Stderr io.Writer
+}
+
+// DefaultKillTimeout is the KillTimeout of a ProcessSpec that does not set
+// one. A ward waiting twice as long still exits within DefaultShutdown.
+const DefaultKillTimeout = 2 * time.Second
+
+// ProcessWard returns a ward that runs the process described by spec.
+// The ward pulses whenever the process writes to its heartbeat and closes
+// its heartbeat once the process exits, so a steward restarts the process
+// both when it goes quiet and when it exits. When the ward is told to stop
+// it sends the process SIGTERM, then SIGKILL after spec.KillTimeout.
+func ProcessWard(spec ProcessSpec) StartGoroutineFn {
+	if spec.KillTimeout == 0 {
+		spec.KillTimeout = DefaultKillTimeout
+	}
+	return func(done <-chan interface{}, pulseInterval time.Duration) <-chan interface{} {
+		heartbeat := make(chan interface{})
+		go func() {
+			defer close(heartbeat)
+
+			pulses := make(chan struct{}, 1)
+			cmd, cleanup, err := startProcess(spec, pulseInterval, pulses)
+			if err != nil {
+				log.Printf("process %v: %v", spec.Path, err)
+				return
+			}
+			defer cleanup()
+			exited := make(chan error, 1)
+			go func() { exited <- cmd.Wait() }()
+
+			for {
+				select {
+				case <-done:
+					terminate(cmd, spec, exited)
+					return
+				case err := <-exited:
+					log.Printf("process %v: exited: %v", spec.Path, exitStatus(err))
+					return
+				case <-pulses:
+					select {
+					case heartbeat <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+		return heartbeat
+	}
+}
+
+// startProcess starts the process with a heartbeat that signals pulses.
+// cleanup releases the heartbeat once the process has exited.
+func startProcess(spec ProcessSpec, pulseInterval time.Duration, pulses chan<- struct{}) (*exec.Cmd, func(), error) {
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Stdout, cmd.Stderr = spec.Stdout, spec.Stderr
+	cmd.WaitDelay = spec.KillTimeout
+	cmd.Env = spec.Env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, EnvPulseInterval+"="+pulseInterval.String())
+
+	switch spec.Heartbeat {
+	case HeartbeatSocket:
+		dir, err := os.MkdirTemp("", "ward")
+		if err != nil {
+			return nil, nil, err
+		}
+		path := filepath.Join(dir, "heartbeat.sock")
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, nil, err
+		}
+		cleanup := func() {
+			l.Close()
+			os.RemoveAll(dir)
+		}
+		cmd.Env = append(cmd.Env, EnvHeartbeatSocket+"="+path)
+		if err := cmd.Start(); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			readPulses(conn, pulses)
+		}()
+		return cmd, cleanup, nil
+
+	default:
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		cmd.ExtraFiles = []*os.File{w}
+		cmd.Env = append(cmd.Env, EnvHeartbeatFD+"=3")
+		err = cmd.Start()
+		// The process has its own copy of w, so r reaches EOF once it
+		// exits.
+		w.Close()
+		if err != nil {
+			r.Close()
+			return nil, nil, err
+		}
+		go func() {
+			defer r.Close()
+			readPulses(r, pulses)
+		}()
+		return cmd, func() {}, nil
+	}
+}
+
+// readPulses signals pulses for every read from r until r fails.
+func readPulses(r io.Reader, pulses chan<- struct{}) {
+	buf := make([]byte, 64)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return
+		}
+		select {
+		case pulses <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// terminate sends the process SIGTERM and, if it has not exited after
+// spec.KillTimeout, SIGKILL. It returns once the process has exited.
+func terminate(cmd *exec.Cmd, spec ProcessSpec, exited <-chan error) {
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-exited:
+		return
+	case <-time.After(spec.KillTimeout):
+	}
+	log.Printf("process %v: still running %v after SIGTERM; killing", spec.Path, spec.KillTimeout)
+	cmd.Process.Kill()
+	<-exited
+}
+
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+// ProcessHeartbeat is called inside a process run by ProcessWard. It opens
+// the heartbeat to write pulses to and returns how often to pulse.
+func ProcessHeartbeat() (io.WriteCloser, time.Duration, error) {
+	interval, err := time.ParseDuration(os.Getenv(EnvPulseInterval))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%v: %w", EnvPulseInterval, err)
+	}
+	if path := os.Getenv(EnvHeartbeatSocket); path != "" {
+		conn, err := net.Dial("unix", path)
+		return conn, interval, err
+	}
+	fd, err := strconv.Atoi(os.Getenv(EnvHeartbeatFD))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%v: %w", EnvHeartbeatFD, err)
+	}
+	return os.NewFile(uintptr(fd), "heartbeat"), interval, nil
+}
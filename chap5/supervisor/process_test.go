@@ -0,0 +1,235 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/streamtest"
+)
+
+// TestHelperProcess is the process run by the process wards below; it does
+// nothing unless SUPERVISOR_HELPER says how to behave.
+func TestHelperProcess(t *testing.T) {
+	behaviour := os.Getenv("SUPERVISOR_HELPER")
+	if behaviour == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	switch behaviour {
+	case "exit":
+		os.Exit(1)
+	case "silent":
+		time.Sleep(time.Hour)
+	case "stubborn":
+		signal.Ignore(syscall.SIGTERM)
+	}
+	heartbeat, interval, err := ProcessHeartbeat()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Println("ward started")
+	for {
+		heartbeat.Write([]byte{1})
+		time.Sleep(interval)
+	}
+}
+
+// helperProcess describes this test binary running TestHelperProcess.
+func helperProcess(behaviour string) ProcessSpec {
+	return ProcessSpec{
+		Path: os.Args[0],
+		Args: []string{"-test.run=^TestHelperProcess$"},
+		Env:  append(os.Environ(), "SUPERVISOR_HELPER="+behaviour),
+	}
+}
+
+// output is a writer that sends everything written to it on a channel.
+type output chan string
+
+func (o output) Write(p []byte) (int, error) {
+	o <- string(p)
+	return len(p), nil
+}
+
+func TestProcessWard(t *testing.T) {
+	tests := []struct {
+		name      string
+		heartbeat HeartbeatTransport
+	}{
+		{name: "pipe", heartbeat: HeartbeatPipe},
+		{name: "socket", heartbeat: HeartbeatSocket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamtest.NoLeaks(t)
+			done := make(chan interface{})
+			defer close(done)
+
+			path := filepath.Join(t.TempDir(), "ward.log")
+			logFile, err := OpenRotatingFile(path, 1<<20, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logFile.Close()
+			spec := helperProcess("pulse")
+			spec.Heartbeat = tt.heartbeat
+			spec.Stdout, spec.Stderr = logFile, logFile
+
+			steward := NewSteward(200*time.Millisecond, ProcessWard(spec))
+			events, unsubscribe := steward.Subscribe(10)
+			defer unsubscribe()
+			steward.Start(done, time.Hour)
+
+			receiveEvents(t, events, 1)
+			select {
+			case e := <-events:
+				t.Fatalf("expected the process to keep pulsing, but received %+v", e)
+			case <-time.After(500 * time.Millisecond):
+			}
+			if err := steward.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := []eventSummary{
+				{Kind: WardExited, Ward: "ward", Attempt: 1},
+				{Kind: StewardStopped},
+			}
+			if actual := summarize(receiveEvents(t, events, 2)); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %v, but received %v", expected, actual)
+			}
+			if logged, _ := os.ReadFile(path); string(logged) != "ward started\n" {
+				t.Errorf("expected the output of the process to be logged, but received %q", logged)
+			}
+		})
+	}
+}
+
+func TestProcessWardExit(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	steward := NewSteward(time.Second, ProcessWard(helperProcess("exit"))).WithIntensity(1, time.Minute)
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	expected := []eventSummary{
+		{Kind: WardStarted, Ward: "ward", Attempt: 1},
+		{Kind: WardExited, Ward: "ward", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardRestarting, Ward: "ward", Attempt: 1, Err: ErrWardExited},
+		{Kind: WardStarted, Ward: "ward", Attempt: 2, Err: ErrWardExited},
+		{Kind: WardExited, Ward: "ward", Attempt: 2, Err: ErrWardExited},
+	}
+	received := receiveEvents(t, events, len(expected)+1)
+	if actual := summarize(received[:len(expected)]); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if stopped := received[len(expected)]; stopped.Kind != StewardStopped || !errors.Is(stopped.Err, ErrTooManyRestarts) {
+		t.Errorf("expected the steward to stop with %v, but received %+v", ErrTooManyRestarts, stopped)
+	}
+}
+
+func TestProcessWardHeartbeatMissed(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	steward := NewSteward(100*time.Millisecond, ProcessWard(helperProcess("silent"))).WithIntensity(1, time.Minute)
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	// The silent process is stopped with SIGTERM before each restart.
+	expected := []eventSummary{
+		{Kind: WardStarted, Ward: "ward", Attempt: 1},
+		{Kind: HeartbeatMissed, Ward: "ward", Attempt: 1, Err: ErrHeartbeatMissed},
+		{Kind: WardRestarting, Ward: "ward", Attempt: 1, Err: ErrHeartbeatMissed},
+		{Kind: WardExited, Ward: "ward", Attempt: 1},
+		{Kind: WardStarted, Ward: "ward", Attempt: 2, Err: ErrHeartbeatMissed},
+		{Kind: HeartbeatMissed, Ward: "ward", Attempt: 2, Err: ErrHeartbeatMissed},
+		{Kind: WardExited, Ward: "ward", Attempt: 2},
+	}
+	received := receiveEvents(t, events, len(expected)+1)
+	if actual := summarize(received[:len(expected)]); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if stopped := received[len(expected)]; stopped.Kind != StewardStopped {
+		t.Errorf("expected the steward to stop, but received %+v", stopped)
+	}
+}
+
+func TestProcessWardKill(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	stdout := make(output, 10)
+	spec := helperProcess("stubborn")
+	spec.Stdout = stdout
+	spec.KillTimeout = 100 * time.Millisecond
+	steward := NewSteward(time.Second, ProcessWard(spec))
+	steward.Start(done, time.Hour)
+
+	select {
+	case line := <-stdout:
+		if !strings.HasPrefix(line, "ward started") {
+			t.Fatalf("expected the process to start, but received %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the process to start")
+	}
+
+	start := time.Now()
+	if err := steward.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < spec.KillTimeout {
+		t.Errorf("expected the process to ignore SIGTERM for %v, but it exited after %v", spec.KillTimeout, elapsed)
+	}
+}
+
+func TestProcessWardKillDefaults(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	stdout := make(output, 10)
+	spec := helperProcess("stubborn")
+	spec.Stdout = stdout
+	steward := NewSteward(time.Second, ProcessWard(spec))
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+	receiveEvents(t, events, 1)
+	select {
+	case <-stdout:
+	case <-time.After(time.Second):
+		t.Fatal("expected the process to start")
+	}
+
+	// The process is killed before the steward's Shutdown passes, so it is
+	// not abandoned.
+	if err := steward.Stop(context.Background()); err != nil {
+		t.Fatalf("expected the process to be killed in time, but received %v", err)
+	}
+	expected := []eventSummary{
+		{Kind: WardExited, Ward: "ward", Attempt: 1},
+		{Kind: StewardStopped},
+	}
+	if actual := summarize(receiveEvents(t, events, 2)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
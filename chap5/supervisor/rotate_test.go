@@ -0,0 +1,52 @@
+package supervisor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ward.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening appends to the current file.
+	f, err = OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("x\n"))
+	f.Close()
+
+	expected := map[string]string{
+		path:        "line4\nx\n",
+		path + ".1": "line3\n",
+		path + ".2": "line2\n",
+	}
+	for name, content := range expected {
+		actual, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != content {
+			t.Errorf("expected %v to hold %q, but received %q", name, content, actual)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 old files to be kept, but received %v", err)
+	}
+	if _, err := f.Write([]byte("closed\n")); err != os.ErrClosed {
+		t.Errorf("expected %v, but received %v", os.ErrClosed, err)
+	}
+}
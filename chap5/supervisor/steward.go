@@ -0,0 +1,84 @@
+package supervisor
+
+import (
+	"context"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream"
+)
+
+// Steward supervises a single ward, restarting it whenever it misses a
+// heartbeat for timeout or exits. It is the steward from chap5/ward.go.
+type Steward struct {
+	sup *Supervisor
+}
+
+// NewSteward returns a steward for the ward started by start.
+func NewSteward(timeout time.Duration, start StartGoroutineFn) *Steward {
+	sup := New(OneForOne, ChildSpec{Name: "ward", Start: start, Timeout: timeout})
+	sup.name = "steward"
+	return &Steward{sup: sup}
+}
+
+// Start starts the ward and supervises it until done is closed or Stop is
+// called. It is a StartGoroutineFn, so a steward can itself be supervised.
+func (s *Steward) Start(done <-chan interface{}, pulseInterval time.Duration) <-chan interface{} {
+	return s.sup.Start(done, pulseInterval)
+}
+
+// WithWardName names the ward in the steward's log lines and events. The
+// default is "ward".
+func (s *Steward) WithWardName(name string) *Steward {
+	s.sup.children[0].Name = name
+	return s
+}
+
+// WithShutdown sets how long the ward gets to close its heartbeat once it
+// is told to stop. See ChildSpec.Shutdown.
+func (s *Steward) WithShutdown(d time.Duration) *Steward {
+	s.sup.children[0].Shutdown = d
+	return s
+}
+
+// WithIntensity makes the steward give up once it has restarted its ward
+// more than maxRestarts times within window. See Supervisor.WithIntensity.
+func (s *Steward) WithIntensity(maxRestarts int, window time.Duration) *Steward {
+	s.sup.WithIntensity(maxRestarts, window)
+	return s
+}
+
+// WithBackoff makes the steward wait before restarting its ward. See
+// Supervisor.WithBackoff.
+func (s *Steward) WithBackoff(b stream.Backoff) *Steward {
+	s.sup.WithBackoff(b)
+	return s
+}
+
+// Restarts reports how many times the ward has been restarted.
+func (s *Steward) Restarts() int {
+	return s.sup.Restarts()
+}
+
+// NextRestart reports when the ward will be restarted, or the zero time if
+// it is not waiting out a backoff.
+func (s *Steward) NextRestart() time.Time {
+	return s.sup.NextRestart()
+}
+
+// Err reports ErrTooManyRestarts once the steward has given up on its ward.
+func (s *Steward) Err() error {
+	return s.sup.Err()
+}
+
+// Subscribe returns a channel of the steward's lifecycle events. See
+// Supervisor.Subscribe.
+func (s *Steward) Subscribe(buffer int) (<-chan Event, func()) {
+	return s.sup.Subscribe(buffer)
+}
+
+// Stop tells the ward to stop and waits for it to exit until ctx is done.
+// It returns nil if the ward exited cleanly and an error wrapping
+// ErrAbandoned if it did not. See Supervisor.Stop.
+func (s *Steward) Stop(ctx context.Context) error {
+	return s.sup.Stop(ctx)
+}
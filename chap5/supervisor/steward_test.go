@@ -0,0 +1,213 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream"
+	"github.com/cipepser/go-concurrency/chap4/stream/streamtest"
+)
+
+func TestSteward(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	NewSteward(10*time.Millisecond, newIrresponsibleWard("ward", starts)).Start(done, time.Hour)
+
+	receiveStarts(t, starts, 3)
+}
+
+func TestStewardIntensity(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	steward := NewSteward(time.Second, newWard("ward", 10, starts)).WithIntensity(3, time.Minute)
+	heartbeat := steward.Start(done, time.Hour)
+
+	receiveStarts(t, starts, 4)
+	if _, ok := <-heartbeat; ok {
+		t.Fatal("expected the steward to give up and close its heartbeat")
+	}
+	if err := steward.Err(); !errors.Is(err, ErrTooManyRestarts) {
+		t.Errorf("expected %v, but received %v", ErrTooManyRestarts, err)
+	}
+	if n := steward.Restarts(); n != 3 {
+		t.Errorf("expected 3 restarts, but received %v", n)
+	}
+	select {
+	case <-starts:
+		t.Error("expected no start after giving up")
+	default:
+	}
+}
+
+func TestStewardBackoff(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	startTimes := make(chan time.Time, 10)
+	starts := make(chan string, 10)
+	ward := newWard("ward", 10, starts)
+	steward := NewSteward(time.Second, func(done <-chan interface{}, pulseInterval time.Duration) <-chan interface{} {
+		startTimes <- time.Now()
+		return ward(done, pulseInterval)
+	})
+	steward.WithIntensity(3, time.Minute).
+		WithBackoff(stream.ExponentialBackoff{Base: 20 * time.Millisecond, Max: time.Second})
+	heartbeat := steward.Start(done, time.Hour)
+
+	receiveStarts(t, starts, 1)
+	startedAt := []time.Time{<-startTimes}
+	var next time.Time
+	for next.IsZero() {
+		next = steward.NextRestart()
+	}
+	if !next.After(startedAt[0]) {
+		t.Errorf("expected the next restart after %v, but received %v", startedAt[0], next)
+	}
+	if n := steward.Restarts(); n != 1 {
+		t.Errorf("expected 1 restart, but received %v", n)
+	}
+
+	receiveStarts(t, starts, 3)
+	<-heartbeat
+	for i := 0; i < 3; i++ {
+		startedAt = append(startedAt, <-startTimes)
+	}
+	for i, wait := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond} {
+		if gap := startedAt[i+1].Sub(startedAt[i]); gap < wait {
+			t.Errorf("restart %v: expected to wait at least %v, but waited %v", i+1, wait, gap)
+		}
+	}
+}
+
+// newSlowWard returns a ward that never pulses and takes exit to close its
+// heartbeat once it is told to stop. running counts the attempts that have
+// not exited yet.
+func newSlowWard(exit time.Duration, running *atomic.Int32, maxRunning *atomic.Int32, starts chan<- string) StartGoroutineFn {
+	return func(done <-chan interface{}, _ time.Duration) <-chan interface{} {
+		starts <- "slow"
+		// Wards are started one at a time, so only this goroutine stores.
+		if n := running.Add(1); n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		heartbeat := make(chan interface{})
+		go func() {
+			defer close(heartbeat)
+			<-done
+			time.Sleep(exit)
+			running.Add(-1)
+		}()
+		return heartbeat
+	}
+}
+
+func TestStewardStop(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	var running, maxRunning atomic.Int32
+	starts := make(chan string, 10)
+	steward := NewSteward(time.Second, newSlowWard(20*time.Millisecond, &running, &maxRunning, starts))
+	heartbeat := steward.Start(done, time.Hour)
+	receiveStarts(t, starts, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := steward.Stop(ctx); err != nil {
+		t.Errorf("expected the ward to exit cleanly, but received %v", err)
+	}
+	if n := running.Load(); n != 0 {
+		t.Errorf("expected the ward to have exited before Stop returned, but %v are running", n)
+	}
+	if _, ok := <-heartbeat; ok {
+		t.Error("expected the steward's heartbeat to close")
+	}
+	if err := steward.Stop(ctx); err != nil {
+		t.Errorf("expected stopping a stopped steward to succeed, but received %v", err)
+	}
+}
+
+func TestStewardStopAbandoned(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	// The irresponsible ward from ward.go never acknowledges done.
+	irresponsible := func(done <-chan interface{}, _ time.Duration) <-chan interface{} {
+		return nil
+	}
+	steward := NewSteward(time.Second, irresponsible)
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := steward.Stop(ctx); !errors.Is(err, ErrAbandoned) {
+		t.Errorf("expected %v, but received %v", ErrAbandoned, err)
+	}
+
+	expected := []EventKind{WardStarted, WardAbandoned, StewardStopped}
+	var actual []EventKind
+	for _, e := range receiveEvents(t, events, len(expected)) {
+		actual = append(actual, e.Kind)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestStewardNilHeartbeat(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	// The irresponsible ward from ward.go returns no heartbeat at all, so
+	// it is abandoned at once instead of costing DefaultShutdown per restart.
+	starts := make(chan string, 10)
+	irresponsible := func(done <-chan interface{}, _ time.Duration) <-chan interface{} {
+		starts <- "ward"
+		return nil
+	}
+	steward := NewSteward(10*time.Millisecond, irresponsible)
+	events, unsubscribe := steward.Subscribe(10)
+	defer unsubscribe()
+	steward.Start(done, time.Hour)
+
+	receiveStarts(t, starts, 3)
+	expected := []EventKind{WardStarted, HeartbeatMissed, WardRestarting, WardAbandoned, WardStarted}
+	var actual []EventKind
+	for _, e := range receiveEvents(t, events, len(expected)) {
+		actual = append(actual, e.Kind)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+}
+
+func TestStewardRestartWaitsForExit(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	var running, maxRunning atomic.Int32
+	starts := make(chan string, 10)
+	NewSteward(10*time.Millisecond, newSlowWard(20*time.Millisecond, &running, &maxRunning, starts)).
+		Start(done, time.Hour)
+
+	receiveStarts(t, starts, 3)
+	if n := maxRunning.Load(); n != 1 {
+		t.Errorf("expected one attempt running at a time, but %v were", n)
+	}
+}
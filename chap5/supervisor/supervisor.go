@@ -0,0 +1,548 @@
+// Package supervisor restarts unhealthy goroutines, following the steward
+// and ward pattern from chap5/ward.go. A Supervisor watches a list of
+// children with an Erlang style restart strategy, and since it is started
+// like any other ward, supervisors nest into supervision trees.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream"
+)
+
+// StartGoroutineFn starts a ward. The ward stops once done is closed and
+// sends on the returned heartbeat at least every pulseInterval while it is
+// healthy. A ward that closes its heartbeat is treated as having exited,
+// and a stopped ward is waited for until it does so, before it is restarted
+// or Stop returns. A ward that returns a nil heartbeat cannot say it has
+// exited, so it is abandoned as soon as it is stopped.
+type StartGoroutineFn func(
+	done <-chan interface{},
+	pulseInterval time.Duration,
+) (heartbeat <-chan interface{})
+
+// Strategy decides which children are restarted when one of them fails.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child.
+	OneForOne Strategy = iota
+	// OneForAll restarts every child.
+	OneForAll
+	// RestForOne restarts the failed child and every child after it.
+	RestForOne
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	}
+	return "one-for-one"
+}
+
+// ChildSpec describes a child of a Supervisor.
+type ChildSpec struct {
+	Name  string
+	Start StartGoroutineFn
+	// Timeout is how long the child may go without a heartbeat before it
+	// is restarted. The child is asked to pulse every Timeout/2. Zero
+	// means the child is only restarted when it exits.
+	Timeout time.Duration
+	// Shutdown is how long the child gets to close its heartbeat once it
+	// is told to stop, before it is abandoned. DefaultShutdown is used if
+	// it is zero. A child with a nil heartbeat is not waited for.
+	Shutdown time.Duration
+}
+
+// DefaultShutdown is the Shutdown of a ChildSpec that does not set one.
+const DefaultShutdown = 5 * time.Second
+
+// ErrTooManyRestarts is the terminal error of a supervisor that restarted
+// its children more often than its intensity allows.
+var ErrTooManyRestarts = errors.New("supervisor: too many restarts")
+
+// Supervisor runs a list of children and restarts them with its strategy
+// when they miss a heartbeat or exit.
+type Supervisor struct {
+	name     string
+	strategy Strategy
+	children []ChildSpec
+
+	maxRestarts int
+	window      time.Duration
+	backoff     stream.Backoff
+
+	mu          sync.Mutex
+	restarts    int
+	nextRestart time.Time
+	err         error
+	subscribers []chan Event
+	current     *run
+}
+
+// New returns a supervisor for children. They are started in order and
+// stopped in reverse order. A child that is stopped, to be restarted or
+// because the supervisor stops, is waited for until it closes its
+// heartbeat, so two attempts of the same ward never run at once.
+func New(strategy Strategy, children ...ChildSpec) *Supervisor {
+	return &Supervisor{name: "supervisor", strategy: strategy, children: children}
+}
+
+// WithName names the supervisor in its log lines and events. The default
+// is "supervisor".
+func (s *Supervisor) WithName(name string) *Supervisor {
+	s.name = name
+	return s
+}
+
+// WithIntensity makes the supervisor give up once it has restarted its
+// children more than maxRestarts times within window. It then stops them
+// all, closes its own heartbeat so that its parent sees it exit, and
+// reports ErrTooManyRestarts from Err.
+func (s *Supervisor) WithIntensity(maxRestarts int, window time.Duration) *Supervisor {
+	s.maxRestarts = maxRestarts
+	s.window = window
+	return s
+}
+
+// WithBackoff makes the supervisor wait before restarting children. The
+// wait grows with every restart and starts over once a whole intensity
+// window passes without one. stream.DecorrelatedJitterBackoff gives an
+// exponential wait with jitter.
+func (s *Supervisor) WithBackoff(b stream.Backoff) *Supervisor {
+	s.backoff = b
+	return s
+}
+
+// Restarts reports how many times the supervisor has restarted children
+// since it was started.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// NextRestart reports when the children waiting out a backoff will be
+// restarted, or the zero time if none are.
+func (s *Supervisor) NextRestart() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextRestart
+}
+
+// Err reports why the supervisor stopped on its own, or nil if it has not.
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+type child struct {
+	spec      ChildSpec
+	done      chan interface{}
+	heartbeat <-chan interface{}
+	deadline  time.Time
+	pending   bool
+	exited    bool
+
+	draining      bool
+	drainDeadline time.Time
+
+	attempt       int
+	started       time.Time
+	lastHeartbeat time.Time
+	err           error
+}
+
+func (c *child) start(done <-chan interface{}) {
+	c.attempt++
+	c.started = time.Now()
+	c.lastHeartbeat = time.Time{}
+	c.done = make(chan interface{})
+	c.heartbeat = c.spec.Start(stream.Or(c.done, done), c.spec.Timeout/2)
+	c.pending, c.exited = false, false
+	if c.spec.Timeout > 0 {
+		c.deadline = c.started.Add(c.spec.Timeout)
+	}
+}
+
+// stop tells the child to stop and leaves it pending a restart. Unless it
+// has already exited, it drains until it closes its heartbeat or its
+// Shutdown passes, which is at once for a nil heartbeat.
+func (c *child) stop() {
+	close(c.done)
+	c.deadline = time.Time{}
+	c.pending = true
+	if c.exited {
+		return
+	}
+	shutdown := c.spec.Shutdown
+	if shutdown == 0 {
+		shutdown = DefaultShutdown
+	}
+	if c.heartbeat == nil {
+		shutdown = 0
+	}
+	c.draining = true
+	c.drainDeadline = time.Now().Add(shutdown)
+}
+
+// seen moves the child's deadline on after a heartbeat.
+func (c *child) seen() {
+	c.lastHeartbeat = time.Now()
+	if c.spec.Timeout > 0 {
+		c.deadline = c.lastHeartbeat.Add(c.spec.Timeout)
+	}
+}
+
+type stopRequest struct {
+	ctx    context.Context
+	result chan<- error
+}
+
+// run is the state of one Start of a supervisor.
+type run struct {
+	*Supervisor
+	done      <-chan interface{}
+	stop      chan stopRequest
+	finished  chan struct{}
+	stopErr   error
+	children  []*child
+	restarted []time.Time
+	wait      time.Duration
+	restartAt time.Time
+}
+
+// Start starts the children and supervises them until done is closed or
+// Stop is called. It is a StartGoroutineFn, so a Supervisor can be the
+// child of another one: its heartbeat closes only once its own children
+// have exited or been abandoned.
+func (s *Supervisor) Start(done <-chan interface{}, pulseInterval time.Duration) <-chan interface{} {
+	r := &run{
+		Supervisor: s,
+		done:       done,
+		stop:       make(chan stopRequest),
+		finished:   make(chan struct{}),
+		children:   make([]*child, len(s.children)),
+	}
+	s.mu.Lock()
+	s.restarts, s.nextRestart, s.err = 0, time.Time{}, nil
+	s.current = r
+	s.mu.Unlock()
+
+	heartbeat := make(chan interface{}, 1)
+	go func() {
+		defer close(heartbeat)
+		defer close(r.finished)
+
+		for i, spec := range s.children {
+			r.children[i] = &child{spec: spec}
+			r.start(r.children[i])
+		}
+		// A parent that does not watch the heartbeat may pass a zero
+		// pulseInterval, in which case the supervisor never pulses.
+		var pulses <-chan time.Time
+		if pulseInterval > 0 {
+			pulse := time.NewTicker(pulseInterval)
+			defer pulse.Stop()
+			pulses = pulse.C
+		}
+
+		stopCtx := context.Background()
+		var stopped chan<- error
+		defer func() {
+			r.stopErr = r.shutdown(stopCtx)
+			s.mu.Lock()
+			if s.current == r {
+				s.current = nil
+			}
+			err := s.err
+			s.mu.Unlock()
+			if err == nil {
+				err = r.stopErr
+			}
+			s.publish(Event{Kind: StewardStopped, Err: err})
+			if stopped != nil {
+				stopped <- r.stopErr
+			}
+		}()
+
+		// cases holds done, stop requests, the pulse, the next deadline,
+		// restart or drain deadline and then the heartbeat of every child.
+		const firstChild = 4
+		cases := make([]reflect.SelectCase, firstChild+len(r.children))
+		cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)}
+		cases[1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.stop)}
+		cases[2] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pulses)}
+		for {
+			var wake <-chan time.Time
+			var timer *time.Timer
+			if next, ok := r.nextWake(); ok {
+				timer = time.NewTimer(time.Until(next))
+				wake = timer.C
+			}
+			cases[3] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(wake)}
+			for i, c := range r.children {
+				cases[firstChild+i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.heartbeat)}
+			}
+
+			chosen, v, ok := reflect.Select(cases)
+			if timer != nil {
+				timer.Stop()
+			}
+			var err error
+			switch chosen {
+			case 0:
+				return
+			case 1:
+				req := v.Interface().(stopRequest)
+				stopCtx, stopped = req.ctx, req.result
+				return
+			case 2:
+				select {
+				case heartbeat <- struct{}{}:
+				default:
+				}
+			case 3:
+				err = r.wake()
+			default:
+				c := r.children[chosen-firstChild]
+				switch {
+				case c.draining:
+					if !ok {
+						r.drained(c)
+						r.startReady()
+					}
+				case ok:
+					c.seen()
+				default:
+					log.Printf("%v: %v exited; restarting", s.name, c.spec.Name)
+					c.err = ErrWardExited
+					c.heartbeat, c.exited = nil, true
+					s.publish(c.event(WardExited))
+					err = r.restart(chosen - firstChild)
+				}
+			}
+			if err != nil {
+				log.Printf("%v: restarted more than %d times in %v; giving up", s.name, s.maxRestarts, s.window)
+				s.mu.Lock()
+				s.err = err
+				s.nextRestart = time.Time{}
+				s.mu.Unlock()
+				return
+			}
+		}
+	}()
+	return heartbeat
+}
+
+// Stop stops the supervisor and its children, the last child first. It
+// waits for each child to close its heartbeat until the child's Shutdown
+// passes or ctx is done, whichever comes first. It returns nil if every
+// child exited cleanly and an error wrapping ErrAbandoned that names the
+// ones that did not. Stop returns nil if the supervisor is not running.
+func (s *Supervisor) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	r := s.current
+	s.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+
+	result := make(chan error, 1)
+	select {
+	case r.stop <- stopRequest{ctx: ctx, result: result}:
+		return <-result
+	case <-r.finished:
+		return r.stopErr
+	}
+}
+
+// shutdown stops every child, the last one first, waiting for each to
+// exit before stopping the next.
+func (r *run) shutdown(ctx context.Context) error {
+	var abandoned []string
+	for i := len(r.children) - 1; i >= 0; i-- {
+		c := r.children[i]
+		if !c.pending {
+			c.stop()
+		}
+		if c.draining && !r.drain(ctx, c) {
+			abandoned = append(abandoned, c.spec.Name)
+		}
+	}
+	if len(abandoned) > 0 {
+		return fmt.Errorf("%w: %v", ErrAbandoned, strings.Join(abandoned, ", "))
+	}
+	return nil
+}
+
+// drain waits for the draining child c to exit and reports whether it did.
+func (r *run) drain(ctx context.Context, c *child) bool {
+	timer := time.NewTimer(time.Until(c.drainDeadline))
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-c.heartbeat:
+			if !ok {
+				r.drained(c)
+				return true
+			}
+		case <-timer.C:
+			r.abandon(c)
+			return false
+		case <-ctx.Done():
+			r.abandon(c)
+			return false
+		}
+	}
+}
+
+// drained records that the draining child c closed its heartbeat.
+func (r *run) drained(c *child) {
+	c.draining = false
+	c.heartbeat, c.exited = nil, true
+	e := c.event(WardExited)
+	e.Err = nil
+	r.publish(e)
+}
+
+// abandon gives up waiting for the draining child c to exit.
+func (r *run) abandon(c *child) {
+	log.Printf("%v: %v did not exit; abandoning", r.name, c.spec.Name)
+	c.draining = false
+	c.heartbeat = nil
+	c.err = ErrAbandoned
+	r.publish(c.event(WardAbandoned))
+}
+
+// nextWake returns the earliest heartbeat deadline, pending restart or
+// drain deadline.
+func (r *run) nextWake() (time.Time, bool) {
+	next := r.restartAt
+	for _, c := range r.children {
+		deadline := c.deadline
+		if c.draining {
+			deadline = c.drainDeadline
+		}
+		if !deadline.IsZero() && (next.IsZero() || deadline.Before(next)) {
+			next = deadline
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// wake abandons the children that outstayed their Shutdown, restarts the
+// ones that are ready and the first child whose heartbeat deadline has
+// passed.
+func (r *run) wake() error {
+	now := time.Now()
+	for _, c := range r.children {
+		if c.draining && !now.Before(c.drainDeadline) {
+			r.abandon(c)
+		}
+	}
+	r.startReady()
+	for i, c := range r.children {
+		if !c.deadline.IsZero() && !now.Before(c.deadline) {
+			log.Printf("%v: %v unhealthy; restarting", r.name, c.spec.Name)
+			c.err = ErrHeartbeatMissed
+			r.publish(c.event(HeartbeatMissed))
+			return r.restart(i)
+		}
+	}
+	return nil
+}
+
+// restart stops the children the strategy picks after children[failed]
+// failed. They are started again once they have all exited and the
+// backoff is over.
+func (r *run) restart(failed int) error {
+	now := time.Now()
+	if n := len(r.restarted); r.window > 0 && n > 0 && now.Sub(r.restarted[n-1]) > r.window {
+		r.wait = 0
+	}
+	kept := r.restarted[:0]
+	for _, t := range r.restarted {
+		if now.Sub(t) <= r.window {
+			kept = append(kept, t)
+		}
+	}
+	r.restarted = append(kept, now)
+	if r.maxRestarts > 0 && len(r.restarted) > r.maxRestarts {
+		return fmt.Errorf("%w: more than %d in %v", ErrTooManyRestarts, r.maxRestarts, r.window)
+	}
+
+	from, to := failed, failed+1
+	switch r.strategy {
+	case OneForAll:
+		from, to = 0, len(r.children)
+	case RestForOne:
+		to = len(r.children)
+	}
+	if r.backoff != nil && r.restartAt.IsZero() {
+		r.wait = r.backoff.Next(r.wait)
+		r.restartAt = now.Add(r.wait)
+	}
+	for i := to - 1; i >= from; i-- {
+		c := r.children[i]
+		if c.pending {
+			continue
+		}
+		c.stop()
+		c.err = r.children[failed].err
+		e := c.event(WardRestarting)
+		e.NextStart = r.restartAt
+		if e.NextStart.IsZero() {
+			e.NextStart = now
+		}
+		r.publish(e)
+	}
+	r.mu.Lock()
+	r.restarts++
+	r.nextRestart = r.restartAt
+	r.mu.Unlock()
+
+	r.startReady()
+	return nil
+}
+
+// start starts c and publishes WardStarted.
+func (r *run) start(c *child) {
+	c.start(r.done)
+	r.publish(c.event(WardStarted))
+}
+
+// startReady starts, in order, every child waiting to be restarted, once
+// the backoff is over and none of them is still draining.
+func (r *run) startReady() {
+	pending := false
+	for _, c := range r.children {
+		if c.draining {
+			return
+		}
+		pending = pending || c.pending
+	}
+	if !pending || (!r.restartAt.IsZero() && time.Now().Before(r.restartAt)) {
+		return
+	}
+	for _, c := range r.children {
+		if c.pending {
+			r.start(c)
+		}
+	}
+	r.restartAt = time.Time{}
+	r.mu.Lock()
+	r.nextRestart = time.Time{}
+	r.mu.Unlock()
+}
@@ -0,0 +1,165 @@
+package supervisor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream/streamtest"
+)
+
+// newWard returns a ward that reports name on starts every time it is
+// started. It exits straight away on its first failures starts and pulses
+// until done is closed after that.
+func newWard(name string, failures int, starts chan<- string) StartGoroutineFn {
+	return func(done <-chan interface{}, pulseInterval time.Duration) <-chan interface{} {
+		starts <- name
+		heartbeat := make(chan interface{})
+		if failures > 0 {
+			failures--
+			close(heartbeat)
+			return heartbeat
+		}
+		go func() {
+			defer close(heartbeat)
+			pulse := time.NewTicker(pulseInterval)
+			defer pulse.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-pulse.C:
+					select {
+					case heartbeat <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+		return heartbeat
+	}
+}
+
+// newIrresponsibleWard returns a ward that never pulses, like the one in
+// ward.go, but exits when it is told to.
+func newIrresponsibleWard(name string, starts chan<- string) StartGoroutineFn {
+	return func(done <-chan interface{}, _ time.Duration) <-chan interface{} {
+		starts <- name
+		heartbeat := make(chan interface{})
+		go func() {
+			defer close(heartbeat)
+			<-done
+		}()
+		return heartbeat
+	}
+}
+
+// receiveStarts waits for n ward starts.
+func receiveStarts(t *testing.T, starts <-chan string, n int) []string {
+	t.Helper()
+	var received []string
+	for len(received) < n {
+		select {
+		case name := <-starts:
+			received = append(received, name)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %v starts, but received %v", n, received)
+		}
+	}
+	return received
+}
+
+func TestSupervisorStrategies(t *testing.T) {
+	tests := []struct {
+		strategy Strategy
+		expected []string
+	}{
+		{strategy: OneForOne, expected: []string{"a", "b", "c", "b"}},
+		{strategy: OneForAll, expected: []string{"a", "b", "c", "a", "b", "c"}},
+		{strategy: RestForOne, expected: []string{"a", "b", "c", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			streamtest.NoLeaks(t)
+			done := make(chan interface{})
+			defer close(done)
+
+			starts := make(chan string, 10)
+			sup := New(tt.strategy,
+				ChildSpec{Name: "a", Start: newWard("a", 0, starts), Timeout: time.Second},
+				ChildSpec{Name: "b", Start: newWard("b", 1, starts), Timeout: time.Second},
+				ChildSpec{Name: "c", Start: newWard("c", 0, starts), Timeout: time.Second},
+			)
+			sup.Start(done, time.Hour)
+
+			if actual := receiveStarts(t, starts, len(tt.expected)); !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, but received %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSupervisorMissedHeartbeat(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	irresponsible := newIrresponsibleWard("irresponsible", starts)
+	New(OneForOne, ChildSpec{Name: "irresponsible", Start: irresponsible, Timeout: 10 * time.Millisecond}).
+		Start(done, time.Hour)
+
+	receiveStarts(t, starts, 3)
+}
+
+func TestSupervisorTree(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	starts := make(chan string, 10)
+	inner := New(OneForOne, ChildSpec{Name: "b", Start: newWard("b", 2, starts), Timeout: time.Second})
+	outer := New(OneForAll,
+		ChildSpec{Name: "a", Start: newWard("a", 0, starts), Timeout: time.Second},
+		ChildSpec{Name: "inner", Start: inner.Start, Timeout: 100 * time.Millisecond},
+	)
+	heartbeat := outer.Start(done, 5*time.Millisecond)
+
+	// The inner supervisor deals with b on its own and keeps pulsing, so
+	// the outer one never restarts a.
+	expected := []string{"a", "b", "b", "b"}
+	if actual := receiveStarts(t, starts, len(expected)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	for i := 0; i < 50; i++ {
+		<-heartbeat
+	}
+	select {
+	case name := <-starts:
+		t.Errorf("expected no more starts, but %v was started", name)
+	default:
+	}
+}
+
+func TestSupervisorTreeZeroTimeout(t *testing.T) {
+	streamtest.NoLeaks(t)
+	done := make(chan interface{})
+	defer close(done)
+
+	// Without a Timeout the inner supervisor is asked to pulse every 0, so
+	// it must not pulse at all.
+	starts := make(chan string, 10)
+	inner := New(OneForOne, ChildSpec{Name: "b", Start: newWard("b", 1, starts), Timeout: time.Second})
+	outer := New(OneForOne, ChildSpec{Name: "inner", Start: inner.Start})
+	outer.Start(done, time.Hour)
+
+	expected := []string{"b", "b"}
+	if actual := receiveStarts(t, starts, len(expected)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but received %v", expected, actual)
+	}
+	if err := outer.Stop(context.Background()); err != nil {
+		t.Errorf("expected a clean stop, but received %v", err)
+	}
+}
@@ -1,43 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/cipepser/go-concurrency/chap4/stream"
+	"github.com/cipepser/go-concurrency/chap5/supervisor"
 )
 
 func main() {
-	var or func(channels ...<-chan interface{}) <-chan interface{}
-	or = func(channels ...<-chan interface{}) <-chan interface{} {
-		switch len(channels) {
-		case 0:
-			return nil
-		case 1:
-			return channels[0]
-		}
-
-		orDone := make(chan interface{})
-		go func() {
-			defer close(orDone)
-			switch len(channels) {
-			case 2:
-				select {
-				case <-channels[0]:
-				case <-channels[1]:
-				}
-			default:
-				select {
-				case <-channels[0]:
-				case <-channels[1]:
-				case <-channels[2]:
-				case <-or(append(channels[3:], orDone)...):
-				}
-			}
-		}()
-		return orDone
-	}
-
 	orDone := func(done, c <-chan interface{}) <-chan interface{} {
 		valStream := make(chan interface{})
 		go func() {
@@ -109,65 +84,11 @@ func main() {
 		return takeStream
 	}
 
-	type startGoroutineFn func(
-		done <-chan interface{},
-		pulseInterval time.Duration,
-	) (heartbeat <-chan interface{})
-
-	newSteward := func(
-		timeout time.Duration,
-		startGoroutine startGoroutineFn,
-	) startGoroutineFn {
-		return func(
-			done <-chan interface{},
-			pulseInterval time.Duration,
-		) <-chan interface{} {
-			heartbeat := make(chan interface{})
-			go func() {
-				defer close(heartbeat)
-
-				var wardDone chan interface{}
-				var wardHeartbeat <-chan interface{}
-				startWard := func() {
-					wardDone = make(chan interface{})
-					wardHeartbeat = startGoroutine(or(wardDone, done), timeout/2)
-				}
-				startWard()
-				pulse := time.Tick(pulseInterval)
-
-			monitorLoop:
-				for {
-					timeoutSignal := time.After(timeout)
-
-					for {
-						select {
-						case <-pulse:
-							select {
-							case heartbeat <- struct{}{}:
-							default:
-							}
-						case <-wardHeartbeat:
-							continue monitorLoop
-						case <-timeoutSignal:
-							log.Println("steward: ward unhealthy; restarting")
-							close(wardDone)
-							startWard()
-							continue monitorLoop
-						case <-done:
-							return
-						}
-					}
-				}
-			}()
-
-			return heartbeat
-		}
-	}
-
 	doWorkFn := func(
 		done <-chan interface{},
+		cp *stream.Checkpoint,
 		intList ...int,
-	) (startGoroutineFn, <-chan interface{}) {
+	) (supervisor.StartGoroutineFn, <-chan interface{}) {
 		intChanStream := make(chan (<-chan interface{}))
 		intStream := bridge(done, intChanStream)
 		doWork := func(
@@ -177,6 +98,7 @@ func main() {
 			intStream := make(chan interface{})
 			heartbeat := make(chan interface{})
 			go func() {
+				defer close(heartbeat)
 				defer close(intStream)
 				select {
 				case intChanStream <- intStream:
@@ -185,30 +107,52 @@ func main() {
 				}
 
 				pulse := time.Tick(pulseInterval)
+				sendPulse := func() {
+					select {
+					case heartbeat <- struct{}{}:
+					default:
+					}
+				}
 
-				for {
-				valueLoop:
-					for _, intVal := range intList {
-						if intVal < 0 {
-							log.Printf("negative value: %v\n", intVal)
-							return
+				// A restarted ward resumes after the last value it sent
+				// instead of starting over.
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+			valueLoop:
+				for v := range stream.Resume(ctx, cp, intList...) {
+					if v.Value < 0 {
+						log.Printf("negative value: %v\n", v.Value)
+						// Skip the bad value once restarted.
+						if err := v.Ack(); err != nil {
+							log.Println(err)
 						}
+						return
+					}
 
-						for {
-							select {
-							case <-pulse:
-								select {
-								case heartbeat <- struct{}{}:
-								default:
-								}
-							case intStream <- intVal:
-								continue valueLoop
-							case <-done:
+					for {
+						select {
+						case <-pulse:
+							sendPulse()
+						case intStream <- v.Value:
+							if err := v.Ack(); err != nil {
+								log.Println(err)
 								return
 							}
+							continue valueLoop
+						case <-done:
+							return
 						}
 					}
 				}
+
+				for {
+					select {
+					case <-pulse:
+						sendPulse()
+					case <-done:
+						return
+					}
+				}
 			}()
 			return heartbeat
 		}
@@ -220,21 +164,25 @@ func main() {
 
 	//doWork := func(done <-chan interface{}, _ time.Duration) <-chan interface{} {
 	//	log.Println("ward: Hello, I'm irresponsible!")
+	//	heartbeat := make(chan interface{})
 	//	go func() {
+	//		defer close(heartbeat)
 	//		<-done
 	//		log.Println("ward: I am halting.")
 	//	}()
-	//	return nil
+	//	return heartbeat
 	//}
-	//doWorkWithSteward := newSteward(4*time.Second, doWork)
+	//steward := supervisor.NewSteward(4*time.Second, doWork)
 	//
 	//done := make(chan interface{})
 	//time.AfterFunc(9*time.Second, func() {
 	//	log.Println("main: halting steward and wart.")
-	//	close(done)
+	//	if err := steward.Stop(context.Background()); err != nil {
+	//		log.Println(err)
+	//	}
 	//})
 	//
-	//for range doWorkWithSteward(done, 4*time.Second) {
+	//for range steward.Start(done, 4*time.Second) {
 	//}
 	//log.Println("Done")
 
@@ -249,14 +197,44 @@ func main() {
 	//08:50:23 Done
 	//08:50:23 ward: I am halting.
 
+	// steward.Stop waits for the ward to halt before Done
+	//19:37:20 ward: Hello, I'm irresponsible!
+	//19:37:24 steward: ward unhealthy; restarting
+	//19:37:24 ward: I am halting.
+	//19:37:24 ward: Hello, I'm irresponsible!
+	//19:37:28 steward: ward unhealthy; restarting
+	//19:37:28 ward: I am halting.
+	//19:37:28 ward: Hello, I'm irresponsible!
+	//19:37:29 main: halting steward and wart.
+	//19:37:29 ward: I am halting.
+	//19:37:29 Done
+
 	done := make(chan interface{})
 	defer close(done)
 
-	doWork, intStream := doWorkFn(done, 1, 2, -1, 3, 4, 5)
-	doWorkWithSteward := newSteward(1*time.Millisecond, doWork)
-	doWorkWithSteward(done, 1*time.Hour)
+	dir, err := os.MkdirTemp("", "ward")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cp, err := stream.OpenCheckpoint(filepath.Join(dir, "intList.checkpoint"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	for intVal := range take(done, intStream, 6) {
+	doWork, intStream := doWorkFn(done, cp, 1, 2, -1, 3, 4, 5)
+	steward := supervisor.NewSteward(1*time.Millisecond, doWork).
+		WithIntensity(5, time.Second).
+		WithBackoff(stream.DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: time.Second})
+	steward.Start(done, 1*time.Hour)
+	// Stop the ward before its checkpoint directory is removed.
+	defer func() {
+		if err := steward.Stop(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	for intVal := range take(done, intStream, 5) {
 		fmt.Printf("Received: %v\n", intVal)
 	}
 	//09:10:19 negative value: -1
@@ -270,4 +248,29 @@ func main() {
 	//Received: 1
 	//Received: 2
 	//09:10:19 negative value: -1
+
+	// the ward closes its heartbeat when it exits, so the steward restarts
+	// it without waiting for the timeout
+	//Received: 1
+	//19:37:29 negative value: -1
+	//19:37:29 steward: ward exited; restarting
+	//Received: 2
+	//Received: 1
+	//19:37:29 negative value: -1
+	//19:37:29 steward: ward exited; restarting
+	//Received: 2
+	//Received: 1
+	//19:37:29 negative value: -1
+	//19:37:29 steward: ward exited; restarting
+	//Received: 2
+
+	// the ward acknowledges every value in a stream.Checkpoint, so the
+	// restarted ward resumes after -1 instead of starting over
+	//Received: 1
+	//Received: 2
+	//19:50:29 negative value: -1
+	//19:50:29 steward: ward exited; restarting
+	//Received: 3
+	//Received: 4
+	//Received: 5
 }